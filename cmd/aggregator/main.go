@@ -1,6 +1,10 @@
 package main
 
 import (
+	"os"
+	"os/signal"
+	"syscall"
+
 	"go.uber.org/zap"
 
 	"github.com/seventv/7tv-bot/internal/aggregator"
@@ -23,7 +27,12 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+
 	select {
-	// TODO: shutdown
+	case <-shutdown:
+		zap.L().Info("Shutting down...")
+		svc.Shutdown()
 	}
 }
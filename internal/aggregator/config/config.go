@@ -1,10 +1,14 @@
 package config
 
 import (
+	"time"
+
 	"github.com/fsnotify/fsnotify"
 	"github.com/gookit/config/v2"
 	"github.com/gookit/config/v2/yaml"
 	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/stream"
 )
 
 // OnChange is called when a config change is detected, can be set during runtime
@@ -13,7 +17,48 @@ var OnChange func()
 type Config struct {
 	LogLevel   string
 	Maxworkers int
-	Mongo      struct {
+	Flush      struct {
+		// Interval between writes of the accumulated emote counts to the database, defaults to 5 seconds.
+		// Messages are acked once their counts are written, so it's clamped to a quarter of the ack wait of the consumer.
+		Interval time.Duration
+		// MaxMessages flushes early once this many messages were counted, defaults to 10000
+		MaxMessages int
+		// Retention is how long the keys of counted messages are kept to skip redeliveries, defaults to 24 hours.
+		// Must be longer than messages can stay in the stream. Counting exactly once requires MongoDB to run as a replica set.
+		Retention time.Duration
+	}
+	Users struct {
+		// Enabled counts the emotes per chatter, chatters on the opt out list are skipped
+		Enabled bool
+		// PerChannel counts the emotes of a chatter per channel, instead of across all channels
+		PerChannel bool
+	}
+	Buckets struct {
+		// RollupInterval between rollups of the minute buckets into hour & day buckets, defaults to 5 minutes
+		RollupInterval time.Duration
+		// Retention of the usage buckets per granularity, minutes default to 48 hours & hours to 90 days.
+		// Days are kept forever unless set. Hours are rolled up from minutes, so those must be kept for at least 2 hours,
+		// and days from hours, so those must be kept for at least 2 days.
+		Retention struct {
+			Minute time.Duration
+			Hour   time.Duration
+			Day    time.Duration
+		}
+	}
+	Trending struct {
+		// Enabled computes the trending emotes every Interval, defaults to 5 minutes
+		Enabled  bool
+		Interval time.Duration
+		// Window is the recent usage that's compared to the baseline, defaults to 1 hour
+		Window time.Duration
+		// Baseline is the usage before the window, from the hour buckets, defaults to 24 hours. Must be retained as hour buckets.
+		Baseline time.Duration
+		// MinCount is the minimum usage in the window for an emote to trend, defaults to 10
+		MinCount int
+		// Limit of trending emotes per list, defaults to 25
+		Limit int
+	}
+	Mongo struct {
 		ConnectionString string
 		Database         string
 		Collection       string
@@ -24,9 +69,28 @@ type Config struct {
 		URL      string
 		Stream   string
 		Consumer string
-		Topic    struct {
+		// Limits of the stream, only used when we create it, the stream is owned & updated by the irc-reader
+		Limits stream.Config
+		// Delivery overrides the declared delivery settings of our consumer
+		Delivery stream.Consumer
+		Fetch    struct {
+			// Batch is the maximum amount of messages fetched at once, defaults to 100
+			Batch int
+			// MaxWait is how long a fetch waits for a full batch, defaults to 5 seconds
+			MaxWait time.Duration
+		}
+		// Usage publishes the live emote usage per channel to <subject>.<channel id>, and of all channels to <subject>.global,
+		// in the pkg/usage schema. Disabled if Subject is unset.
+		Usage struct {
+			Subject string
+			// Interval the usage is accumulated over before it's published, defaults to 1 second
+			Interval time.Duration
+		}
+		Topic struct {
 			Raw string
-			// provisions support for optional JSON or other parsed format output in the future
+			// Normalized consumes messages in the pkg/message schema from this subject instead of the raw IRC lines,
+			// the format is read from the message headers
+			Normalized string
 		}
 	}
 	Health struct {
@@ -0,0 +1,432 @@
+package aggregator
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	emotedb "github.com/seventv/7tv-bot/pkg/database/emotes"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+// counter accumulates emote counts in memory, and writes them to the database in bulk every flush interval.
+// Messages are only acked once the flush containing their counts succeeded,
+// so the counts of messages that weren't flushed yet are redelivered after a crash instead of being lost.
+// Redelivered messages that were already flushed are recognized by their key, so they aren't counted twice.
+type counter struct {
+	interval    time.Duration
+	maxMessages int
+
+	mx      sync.Mutex
+	pending *batch
+	// full is signalled when the pending batch reached maxMessages, so it's flushed before the consumer runs out of unacked messages
+	full chan struct{}
+
+	// users counts the emotes per chatter, unless they opted out
+	users userOptions
+
+	// onFlush is called with the oldest minute bucket of every successful flush
+	onFlush func(oldest time.Time)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// countKey is the granularity counts are accumulated at
+type countKey struct {
+	emoteID   primitive.ObjectID
+	channelID string
+	bucket    time.Time
+}
+
+// entry is a counted message waiting to be flushed
+type entry struct {
+	msg *nats.Msg
+	// key identifies the message across redeliveries, it's recorded in the same transaction as its counts
+	key       string
+	channelID string
+	userID    string
+	bucket    time.Time
+	emotes    []types.CountedEmote
+}
+
+type batch struct {
+	entries []entry
+	// index maps the key of every entry to its position, so a message redelivered before its batch was flushed is kept once
+	index map[string]int
+	// duplicates are earlier deliveries of messages in entries, they're acked together with them
+	duplicates []*nats.Msg
+}
+
+func newBatch() *batch {
+	return &batch{index: make(map[string]int)}
+}
+
+// add adds the entry, or replaces the earlier delivery of the same message, returns the amount of entries
+func (b *batch) add(e entry) int {
+	if i, ok := b.index[e.key]; ok {
+		b.duplicates = append(b.duplicates, b.entries[i].msg)
+		b.entries[i] = e
+		return len(b.entries)
+	}
+	b.index[e.key] = len(b.entries)
+	b.entries = append(b.entries, e)
+	return len(b.entries)
+}
+
+// msgs returns every delivery of the messages in the batch
+func (b *batch) msgs() []*nats.Msg {
+	msgs := make([]*nats.Msg, 0, len(b.entries)+len(b.duplicates))
+	for _, e := range b.entries {
+		msgs = append(msgs, e.msg)
+	}
+	return append(msgs, b.duplicates...)
+}
+
+func newCounter(interval time.Duration, maxMessages int) *counter {
+	return &counter{
+		interval:    interval,
+		maxMessages: maxMessages,
+		pending:     newBatch(),
+		full:        make(chan struct{}, 1),
+	}
+}
+
+// add adds the counted emotes of a message to the pending batch, the message is acked once the batch is flushed.
+// Messages without emotes are added as well, so they're acked in order with the rest.
+func (c *counter) add(msg *nats.Msg, meta *nats.MsgMetadata, channelID, userID string, counted []types.CountedEmote) {
+	e := entry{
+		msg:       msg,
+		key:       messageKey(meta),
+		channelID: channelID,
+		userID:    userID,
+		bucket:    meta.Timestamp.UTC().Truncate(time.Minute),
+		emotes:    counted,
+	}
+
+	c.mx.Lock()
+	full := c.pending.add(e) >= c.maxMessages
+	c.mx.Unlock()
+
+	if full {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// messageKey identifies a message in the stream, the timestamp is included so keys stay unique if the stream is recreated
+func messageKey(meta *nats.MsgMetadata) string {
+	return strconv.FormatUint(meta.Sequence.Stream, 10) + "-" + strconv.FormatInt(meta.Timestamp.UnixNano(), 10)
+}
+
+// start flushes the pending batch every interval, or once it's full
+func (c *counter) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-c.full:
+			}
+			c.flush(ctx)
+		}
+	}()
+}
+
+// stop flushes what's left after the consumer stopped, messages that can't be flushed in time are redelivered later
+func (c *counter) stop() {
+	c.cancel()
+	<-c.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c.flush(ctx)
+}
+
+// flush writes the counts of the pending batch to the database & acks its messages.
+// The counts are written in a transaction together with the keys of the messages, messages that were already counted
+// before they were redelivered are skipped, so every message is counted exactly once.
+// Failed transactions are retried until they succeed, or ctx is cancelled, the messages are kept in progress in the meantime.
+func (c *counter) flush(ctx context.Context) {
+	c.mx.Lock()
+	b := c.pending
+	c.pending = newBatch()
+	c.mx.Unlock()
+
+	if len(b.entries) == 0 {
+		return
+	}
+
+	// keys are unique within the batch, inserting the same key twice would fail every transaction
+	keys := make([]string, 0, len(b.entries))
+	for _, e := range b.entries {
+		keys = append(keys, e.key)
+	}
+
+	var skipped int
+	backoff := minBackoff
+	for {
+		err := emotedb.WithTransaction(ctx, func(ctx context.Context) error {
+			processed, err := emotedb.Processed(ctx, keys)
+			if err != nil {
+				return err
+			}
+			skipped = len(processed)
+
+			err = emotedb.IncrementEmotes(ctx, b.totals(processed))
+			if err != nil {
+				return err
+			}
+			err = emotedb.IncrementChannelEmotes(ctx, b.channelTotals(processed))
+			if err != nil {
+				return err
+			}
+			err = emotedb.IncrementBuckets(ctx, b.buckets(processed))
+			if err != nil {
+				return err
+			}
+			if c.users.enabled {
+				// the opt outs are read in the transaction, but a new count doesn't conflict with an opt out committed meanwhile,
+				// emotedb.OptOut deletes the counts again after committing to catch those
+				optedOut, err := emotedb.OptedOut(ctx, b.users(processed))
+				if err != nil {
+					return err
+				}
+				err = emotedb.IncrementUserEmotes(ctx, b.userTotals(processed, optedOut, c.users.perChannel))
+				if err != nil {
+					return err
+				}
+			}
+
+			unprocessed := make([]string, 0, len(keys)-len(processed))
+			for _, key := range keys {
+				if !processed[key] {
+					unprocessed = append(unprocessed, key)
+				}
+			}
+			return emotedb.MarkProcessed(ctx, unprocessed)
+		})
+		if err == nil {
+			break
+		}
+		zap.S().Errorw("failed to flush emote counts, retrying", "error", err, "messages", len(b.entries), "backoff", backoff)
+
+		// keep the messages from being redelivered while we retry
+		for _, msg := range b.msgs() {
+			_ = msg.InProgress()
+		}
+		select {
+		case <-ctx.Done():
+			// nothing was written, the messages are redelivered after the ack wait
+			zap.S().Errorw("gave up flushing emote counts", "messages", len(b.entries))
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	for _, msg := range b.msgs() {
+		_ = msg.Ack()
+	}
+	if c.onFlush != nil {
+		c.onFlush(b.oldest())
+	}
+	if skipped > 0 {
+		zap.S().Infow("skipped redelivered messages that were already counted", "messages", skipped)
+	}
+	zap.S().Debugw("flushed emote counts", "messages", len(b.entries))
+}
+
+// counts accumulates the counts per emote, channel & minute, skipping messages that were already processed.
+// emotes holds the data of every counted emote, written when it's inserted.
+func (b *batch) counts(processed map[string]bool) (counts map[countKey]int, emotes map[primitive.ObjectID]types.Emote) {
+	counts = make(map[countKey]int)
+	emotes = make(map[primitive.ObjectID]types.Emote)
+	for _, e := range b.entries {
+		if processed[e.key] {
+			continue
+		}
+		for _, emote := range e.emotes {
+			counts[countKey{emoteID: emote.Emote.EmoteID, channelID: e.channelID, bucket: e.bucket}] += emote.Count
+			emotes[emote.Emote.EmoteID] = emote.Emote
+		}
+	}
+	return counts, emotes
+}
+
+// totals sums the counts of every emote across channels & buckets, for the global stats
+func (b *batch) totals(processed map[string]bool) []types.CountedEmote {
+	counts, emotes := b.counts(processed)
+	sums := make(map[primitive.ObjectID]int, len(emotes))
+	for key, count := range counts {
+		sums[key.emoteID] += count
+	}
+	totals := make([]types.CountedEmote, 0, len(sums))
+	for id, count := range sums {
+		totals = append(totals, types.CountedEmote{Count: count, Emote: emotes[id]})
+	}
+	return totals
+}
+
+// channelTotals sums the counts of every emote per channel across buckets, for the channel stats
+func (b *batch) channelTotals(processed map[string]bool) []emotedb.ChannelEmote {
+	counts, emotes := b.counts(processed)
+	type channelEmote struct {
+		channelID string
+		emoteID   primitive.ObjectID
+	}
+	sums := make(map[channelEmote]int)
+	for key, count := range counts {
+		// raw messages without a room-id tag can't be attributed to a channel
+		if key.channelID == "" {
+			continue
+		}
+		sums[channelEmote{key.channelID, key.emoteID}] += count
+	}
+	totals := make([]emotedb.ChannelEmote, 0, len(sums))
+	for key, count := range sums {
+		totals = append(totals, emotedb.ChannelEmote{
+			ChannelID: key.channelID,
+			Emote:     types.CountedEmote{Count: count, Emote: emotes[key.emoteID]},
+		})
+	}
+	return totals
+}
+
+// users returns the chatters of the messages that weren't processed yet
+func (b *batch) users(processed map[string]bool) []string {
+	seen := make(map[string]bool)
+	users := []string{}
+	for _, e := range b.entries {
+		if processed[e.key] || e.userID == "" || len(e.emotes) == 0 || seen[e.userID] {
+			continue
+		}
+		seen[e.userID] = true
+		users = append(users, e.userID)
+	}
+	return users
+}
+
+// userTotals sums the counts of every emote per chatter, and per channel if perChannel is set.
+// Chatters that opted out are left out.
+func (b *batch) userTotals(processed, optedOut map[string]bool, perChannel bool) []emotedb.UserEmote {
+	type userEmote struct {
+		userID    string
+		channelID string
+		emoteID   primitive.ObjectID
+	}
+	sums := make(map[userEmote]int)
+	emotes := make(map[primitive.ObjectID]types.Emote)
+	for _, e := range b.entries {
+		if processed[e.key] || e.userID == "" || optedOut[e.userID] {
+			continue
+		}
+		var channelID string
+		if perChannel {
+			channelID = e.channelID
+		}
+		for _, emote := range e.emotes {
+			sums[userEmote{e.userID, channelID, emote.Emote.EmoteID}] += emote.Count
+			emotes[emote.Emote.EmoteID] = emote.Emote
+		}
+	}
+	totals := make([]emotedb.UserEmote, 0, len(sums))
+	for key, count := range sums {
+		totals = append(totals, emotedb.UserEmote{
+			UserID:    key.userID,
+			ChannelID: key.channelID,
+			Emote:     types.CountedEmote{Count: count, Emote: emotes[key.emoteID]},
+		})
+	}
+	return totals
+}
+
+// buckets returns the counts per emote, channel & minute bucket
+func (b *batch) buckets(processed map[string]bool) []emotedb.UsageBucket {
+	counts, _ := b.counts(processed)
+	buckets := make([]emotedb.UsageBucket, 0, len(counts))
+	for key, count := range counts {
+		buckets = append(buckets, emotedb.UsageBucket{
+			Bucket:    key.bucket,
+			EmoteID:   key.emoteID,
+			ChannelID: key.channelID,
+			Count:     count,
+		})
+	}
+	return buckets
+}
+
+// oldest returns the oldest bucket in the batch
+func (b *batch) oldest() time.Time {
+	var oldest time.Time
+	for _, e := range b.entries {
+		if oldest.IsZero() || e.bucket.Before(oldest) {
+			oldest = e.bucket
+		}
+	}
+	return oldest
+}
+
+type userOptions struct {
+	enabled    bool
+	perChannel bool
+}
+
+type flushOptions struct {
+	interval    time.Duration
+	maxMessages int
+}
+
+// flushOptions returns the configured flush interval & max messages per flush, or their defaults.
+// The interval is clamped to a quarter of the ack wait, so messages are acked before they're redelivered, even if a flush is retried.
+func (s *Service) flushOptions() flushOptions {
+	opts := flushOptions{
+		interval:    s.cfg.Flush.Interval,
+		maxMessages: s.cfg.Flush.MaxMessages,
+	}
+	if opts.interval <= 0 {
+		opts.interval = 5 * time.Second
+	}
+	if limit := s.ackWait() / 4; opts.interval > limit {
+		zap.S().Warnw("flush interval is too close to the ack wait, clamping it", "interval", opts.interval, "ackWait", s.ackWait(), "clamped", limit)
+		opts.interval = limit
+	}
+	if opts.maxMessages < 1 {
+		opts.maxMessages = 10000
+	}
+	return opts
+}
+
+// ackWait returns the ack wait of our consumer, defaults to 1 minute
+func (s *Service) ackWait() time.Duration {
+	if s.cfg.Nats.Delivery.AckWait <= 0 {
+		return time.Minute
+	}
+	return s.cfg.Nats.Delivery.AckWait
+}
+
+// processedRetention returns how long the keys of counted messages are kept, defaults to 24 hours
+func (s *Service) processedRetention() time.Duration {
+	if s.cfg.Flush.Retention <= 0 {
+		return 24 * time.Hour
+	}
+	return s.cfg.Flush.Retention
+}
@@ -0,0 +1,207 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/seventv/7tv-bot/internal/aggregator/config"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+func Test_counter_add(t *testing.T) {
+	forsenE := types.Emote{Name: "forsenE", EmoteID: primitive.NewObjectID()}
+	okayeg := types.Emote{Name: "okayeg", EmoteID: primitive.NewObjectID()}
+	at := time.Date(2023, 7, 31, 15, 1, 38, 0, time.UTC)
+	meta := func(seq uint64, at time.Time) *nats.MsgMetadata {
+		return &nats.MsgMetadata{Sequence: nats.SequencePair{Stream: seq}, Timestamp: at}
+	}
+
+	c := newCounter(time.Minute, 3)
+	c.add(&nats.Msg{}, meta(1, at), "22484632", "", []types.CountedEmote{{Count: 2, Emote: forsenE}})
+	c.add(&nats.Msg{}, meta(2, at.Add(10*time.Second)), "22484632", "", []types.CountedEmote{{Count: 1, Emote: forsenE}, {Count: 1, Emote: okayeg}})
+	c.add(&nats.Msg{}, meta(3, at.Add(time.Minute)), "71092938", "", []types.CountedEmote{{Count: 4, Emote: forsenE}})
+
+	select {
+	case <-c.full:
+	default:
+		t.Error("add() didn't signal a full batch after max messages")
+	}
+
+	// same emote, channel & minute are accumulated
+	counts, _ := c.pending.counts(nil)
+	if got := counts[countKey{forsenE.EmoteID, "22484632", at.Truncate(time.Minute)}]; got != 3 {
+		t.Errorf("count of forsenE in first bucket = %v, want 3", got)
+	}
+	if len(counts) != 3 {
+		t.Errorf("counts() accumulated %v counts, want 3", len(counts))
+	}
+
+	tests := []struct {
+		name      string
+		processed map[string]bool
+		want      map[string]int
+	}{
+		{
+			name: "nothing processed",
+			want: map[string]int{"forsenE": 7, "okayeg": 1},
+		},
+		{
+			name:      "redelivered message",
+			processed: map[string]bool{messageKey(meta(2, at.Add(10*time.Second))): true},
+			want:      map[string]int{"forsenE": 6},
+		},
+		{
+			name:      "same sequence in a recreated stream",
+			processed: map[string]bool{messageKey(meta(2, at.Add(24*time.Hour))): true},
+			want:      map[string]int{"forsenE": 7, "okayeg": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			totals := make(map[string]int)
+			for _, emote := range c.pending.totals(tt.processed) {
+				totals[emote.Emote.Name] = emote.Count
+			}
+			if len(totals) != len(tt.want) {
+				t.Fatalf("totals() = %v, want %v", totals, tt.want)
+			}
+			for name, count := range tt.want {
+				if totals[name] != count {
+					t.Errorf("totals() = %v, want %v", totals, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_batch_add(t *testing.T) {
+	forsenE := types.Emote{Name: "forsenE", EmoteID: primitive.NewObjectID()}
+	meta := &nats.MsgMetadata{Sequence: nats.SequencePair{Stream: 1}, Timestamp: time.Date(2023, 7, 31, 15, 1, 38, 0, time.UTC)}
+
+	c := newCounter(time.Minute, 10)
+	first, redelivered := &nats.Msg{}, &nats.Msg{}
+	c.add(first, meta, "22484632", "", []types.CountedEmote{{Count: 2, Emote: forsenE}})
+	c.add(redelivered, meta, "22484632", "", []types.CountedEmote{{Count: 2, Emote: forsenE}})
+
+	// the redelivered message is counted once, but both deliveries are acked
+	if len(c.pending.entries) != 1 {
+		t.Errorf("add() kept %v entries of the same message, want 1", len(c.pending.entries))
+	}
+	if c.pending.entries[0].msg != redelivered {
+		t.Error("add() didn't keep the latest delivery")
+	}
+	if msgs := c.pending.msgs(); len(msgs) != 2 {
+		t.Errorf("msgs() = %v deliveries, want 2", len(msgs))
+	}
+	if totals := c.pending.totals(nil); len(totals) != 1 || totals[0].Count != 2 {
+		t.Errorf("totals() = %v, want forsenE counted twice", totals)
+	}
+}
+
+func Test_Service_flushOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		ackWait  time.Duration
+		want     time.Duration
+	}{
+		{name: "defaults", want: 5 * time.Second},
+		{name: "below the ack wait", interval: 10 * time.Second, want: 10 * time.Second},
+		{name: "close to the ack wait", interval: 50 * time.Second, want: 15 * time.Second},
+		{name: "configured ack wait", interval: 50 * time.Second, ackWait: 5 * time.Minute, want: 50 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Flush.Interval = tt.interval
+			cfg.Nats.Delivery.AckWait = tt.ackWait
+			s := &Service{cfg: cfg}
+			if got := s.flushOptions().interval; got != tt.want {
+				t.Errorf("flushOptions().interval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_batch_channelTotals(t *testing.T) {
+	forsenE := types.Emote{Name: "forsenE", EmoteID: primitive.NewObjectID()}
+	at := time.Date(2023, 7, 31, 15, 1, 38, 0, time.UTC)
+	b := &batch{entries: []entry{
+		{key: "1", channelID: "22484632", bucket: at.Truncate(time.Minute), emotes: []types.CountedEmote{{Count: 2, Emote: forsenE}}},
+		{key: "2", channelID: "22484632", bucket: at.Add(time.Minute).Truncate(time.Minute), emotes: []types.CountedEmote{{Count: 3, Emote: forsenE}}},
+		{key: "3", channelID: "71092938", bucket: at.Truncate(time.Minute), emotes: []types.CountedEmote{{Count: 1, Emote: forsenE}}},
+		{key: "4", bucket: at.Truncate(time.Minute), emotes: []types.CountedEmote{{Count: 10, Emote: forsenE}}},
+	}}
+
+	// buckets of the same channel are summed, messages without a channel are left out
+	want := map[string]int{"22484632": 5, "71092938": 1}
+	got := make(map[string]int)
+	for _, emote := range b.channelTotals(nil) {
+		if emote.Emote.Emote.EmoteID != forsenE.EmoteID {
+			t.Errorf("channelTotals() counted emote %v, want %v", emote.Emote.Emote.EmoteID, forsenE.EmoteID)
+		}
+		got[emote.ChannelID] += emote.Emote.Count
+	}
+	if len(got) != len(want) {
+		t.Fatalf("channelTotals() = %v, want %v", got, want)
+	}
+	for channel, count := range want {
+		if got[channel] != count {
+			t.Errorf("channelTotals() = %v, want %v", got, want)
+		}
+	}
+}
+
+func Test_batch_userTotals(t *testing.T) {
+	forsenE := types.Emote{Name: "forsenE", EmoteID: primitive.NewObjectID()}
+	b := &batch{entries: []entry{
+		{key: "1", channelID: "22484632", userID: "1", emotes: []types.CountedEmote{{Count: 2, Emote: forsenE}}},
+		{key: "2", channelID: "71092938", userID: "1", emotes: []types.CountedEmote{{Count: 3, Emote: forsenE}}},
+		{key: "3", channelID: "22484632", userID: "2", emotes: []types.CountedEmote{{Count: 1, Emote: forsenE}}},
+		{key: "4", channelID: "22484632", userID: "3", emotes: []types.CountedEmote{{Count: 4, Emote: forsenE}}},
+		{key: "5", channelID: "22484632", emotes: []types.CountedEmote{{Count: 10, Emote: forsenE}}},
+	}}
+
+	tests := []struct {
+		name       string
+		processed  map[string]bool
+		optedOut   map[string]bool
+		perChannel bool
+		want       map[string]int
+	}{
+		{
+			name: "across channels",
+			want: map[string]int{"1/": 5, "2/": 1, "3/": 4},
+		},
+		{
+			name:       "per channel",
+			perChannel: true,
+			want:       map[string]int{"1/22484632": 2, "1/71092938": 3, "2/22484632": 1, "3/22484632": 4},
+		},
+		{
+			name:      "opted out & processed",
+			processed: map[string]bool{"2": true},
+			optedOut:  map[string]bool{"3": true},
+			want:      map[string]int{"1/": 2, "2/": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]int)
+			for _, emote := range b.userTotals(tt.processed, tt.optedOut, tt.perChannel) {
+				got[emote.UserID+"/"+emote.ChannelID] += emote.Emote.Count
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("userTotals() = %v, want %v", got, tt.want)
+			}
+			for key, count := range tt.want {
+				if got[key] != count {
+					t.Errorf("userTotals() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,21 +1,20 @@
 package aggregator
 
 import (
-	"context"
 	"strings"
 
 	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 
-	emotedb "github.com/seventv/7tv-bot/pkg/database/emotes"
 	"github.com/seventv/7tv-bot/pkg/irc"
+	"github.com/seventv/7tv-bot/pkg/message"
 )
 
 func (s *Service) handleMessage(natsMsg *nats.Msg) error {
 	// TODO: metrics
 	//start := time.Now()
 
-	msg, err := parseMessage(natsMsg.Data)
+	msg, err := decodeMessage(natsMsg)
 	if err != nil {
 		return err
 	}
@@ -27,13 +26,16 @@ func (s *Service) handleMessage(natsMsg *nats.Msg) error {
 		return err
 	}
 
-	for _, emote := range counted {
-		err = emotedb.IncrementEmote(context.TODO(), emote)
-		if err != nil {
-			return err
-		}
+	meta, err := natsMsg.Metadata()
+	if err != nil {
+		return err
+	}
+	// the message is acked once its counts are flushed
+	s.counter.add(natsMsg, meta, msg.Room.ID, msg.Sender.ID, counted)
+	// redeliveries were already published when they were first delivered, or are too old to be live
+	if s.realtime != nil && meta.NumDelivered == 1 {
+		s.realtime.add(msg.Room.ID, counted)
 	}
-	// TODO: push counted to NATS for realtime emote display
 
 	return nil
 }
@@ -52,6 +54,33 @@ type Message struct {
 	Raw          string
 }
 
+// decodeMessage decodes normalized messages according to their Format header, anything else is parsed as raw IRC
+func decodeMessage(natsMsg *nats.Msg) (*Message, error) {
+	format := natsMsg.Header.Get(message.HeaderFormat)
+	if format == "" {
+		return parseMessage(natsMsg.Data)
+	}
+
+	normalized, err := message.Unmarshal(natsMsg.Data, format)
+	if err != nil {
+		return nil, err
+	}
+	return fromNormalized(normalized), nil
+}
+
+func fromNormalized(normalized *message.Message) *Message {
+	msg := &Message{
+		Type:         strings.ToUpper(normalized.Type),
+		MessageWords: strings.Split(normalized.Text, " "),
+		Raw:          normalized.Text,
+	}
+	msg.Sender.ID = normalized.Sender.ID
+	msg.Sender.Username = normalized.Sender.Login
+	msg.Room.ID = normalized.Channel.ID
+	msg.Room.Username = normalized.Channel.Login
+	return msg
+}
+
 func parseMessage(data []byte) (*Message, error) {
 	rawMessage := string(data)
 	msg := &Message{Raw: rawMessage}
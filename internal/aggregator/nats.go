@@ -3,79 +3,126 @@ package aggregator
 import (
 	"context"
 	"errors"
-	"fmt"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/stream"
+)
+
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 30 * time.Second
 )
 
-func (s *Service) subscribe(ctx context.Context, cb func(msg *nats.Msg) error) error {
+// subscribe binds to our durable consumer, the consumer & stream are created if they don't exist yet
+func (s *Service) subscribe() (*nats.Subscription, error) {
 	js, err := s.newJetStream()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return js.PullSubscribe(s.subject(), s.cfg.Nats.Consumer, nats.Bind(s.cfg.Nats.Stream, s.cfg.Nats.Consumer))
+}
 
-	sub, err := js.PullSubscribe(s.cfg.Nats.Topic.Raw+".>", s.cfg.Nats.Consumer)
-	if err != nil {
-		panic(err)
+// consume fetches batches of messages & hands them to a fixed pool of workers, until ctx is cancelled.
+// cb is responsible for acking the messages it handled, failed messages are Nak'd so they're redelivered.
+// Fetching is retried with backoff on errors, e.g. while NATS is unavailable.
+func (s *Service) consume(ctx context.Context, sub *nats.Subscription, cb func(msg *nats.Msg) error) {
+	batch, maxWait := s.fetchOptions()
+	workers := s.cfg.Maxworkers
+	if workers < 1 {
+		workers = 1
 	}
 
-	// limit the amount of workers for the callback
-	sem := make(chan struct{}, s.cfg.Maxworkers)
-
-	for {
-		msg, err := s.fetchOne(ctx, sub)
-		if err != nil {
-			if err.Error() == "fetch: context deadline exceeded" {
-				continue
+	msgs := make(chan *nats.Msg, batch)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				s.process(msg, cb)
 			}
-			panic(err)
+		}()
+	}
+
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		fetched, err := fetch(ctx, sub, batch, maxWait)
+		// messages fetched before an error are still handled
+		for _, msg := range fetched {
+			// blocks while all workers are busy, so we don't fetch more than we can handle
+			msgs <- msg
 		}
-		_, err = msg.Metadata()
-		if err != nil {
-			panic(err)
+		if err == nil {
+			backoff = minBackoff
+			continue
+		}
+		if ctx.Err() != nil {
+			break
 		}
 
-		if err := msg.InProgress(); err != nil {
-			_ = msg.Nak()
-			continue
+		zap.S().Errorw("failed to fetch messages from NATS, retrying", "error", err, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
 		}
-		sem <- struct{}{}
-		go func() {
-			err := cb(msg)
-			if err != nil {
-				zap.S().Error("couldn't process message from NATS", err)
-				// If we cannot process the message, send Nak, so another consumer can try again.
-				// we don't need to explicitly do this, but it does speed things up
-				_ = msg.Nak()
-				<-sem
-				return
-			}
-			_ = msg.Ack()
-			<-sem
-		}()
 	}
-	return nil
+
+	// let the workers finish what we already fetched
+	close(msgs)
+	wg.Wait()
 }
 
-func (s *Service) fetchOne(ctx context.Context, sub *nats.Subscription) (*nats.Msg, error) {
-	msgs, err := sub.Fetch(1, nats.Context(ctx))
+func (s *Service) process(msg *nats.Msg, cb func(msg *nats.Msg) error) {
+	err := cb(msg)
 	if err != nil {
-		return nil, fmt.Errorf("fetch: %w", err)
+		zap.S().Errorw("couldn't process message from NATS", "error", err)
+		// If we cannot process the message, send Nak, so another consumer can try again.
+		// we don't need to explicitly do this, but it does speed things up
+		_ = msg.Nak()
 	}
-	if len(msgs) == 0 {
-		return nil, errors.New("no messages")
+}
+
+// fetch waits up to maxWait for a batch of messages, returns what arrived in the meantime.
+// Not receiving any messages isn't an error.
+func fetch(ctx context.Context, sub *nats.Subscription, batch int, maxWait time.Duration) ([]*nats.Msg, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, maxWait)
+	defer cancel()
+
+	msgs, err := sub.Fetch(batch, nats.Context(fetchCtx))
+	if ctx.Err() == nil && (errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout)) {
+		return msgs, nil
 	}
+	return msgs, err
+}
 
-	return msgs[0], nil
+// fetchOptions returns the configured batch size & max wait of fetches, or their defaults
+func (s *Service) fetchOptions() (int, time.Duration) {
+	batch := s.cfg.Nats.Fetch.Batch
+	if batch < 1 {
+		batch = 100
+	}
+	maxWait := s.cfg.Nats.Fetch.MaxWait
+	if maxWait <= 0 {
+		maxWait = 5 * time.Second
+	}
+	return batch, maxWait
 }
 
 func (s *Service) newJetStream() (nats.JetStreamContext, error) {
-	nc, err := nats.Connect(s.cfg.Nats.URL)
+	// keep reconnecting while NATS is unavailable, fetches are retried in the meantime
+	nc, err := nats.Connect(s.cfg.Nats.URL, nats.MaxReconnects(-1))
 	if err != nil {
 		return nil, err
 	}
+	s.nc = nc
 	js, err := nc.JetStream()
 	if err != nil {
 		return nil, err
@@ -89,43 +136,56 @@ func (s *Service) newJetStream() (nats.JetStreamContext, error) {
 }
 
 func (s *Service) ensureConsumer(js nats.JetStreamContext) error {
-	consumercfg := &nats.ConsumerConfig{
-		Durable:       s.cfg.Nats.Consumer,
-		DeliverGroup:  s.cfg.Nats.Consumer,
-		MaxDeliver:    3,
-		AckWait:       1 * time.Minute,
+	cfg := &nats.ConsumerConfig{
+		Durable:      s.cfg.Nats.Consumer,
+		DeliverGroup: s.cfg.Nats.Consumer,
+		MaxDeliver:   3,
+		AckWait:      s.ackWait(),
+		// messages are acked in batches when their counts are flushed, leave room for the next batch in the meantime
+		MaxAckPending: 2 * s.flushOptions().maxMessages,
 		AckPolicy:     nats.AckExplicitPolicy,
 		DeliverPolicy: nats.DeliverAllPolicy,
-		FilterSubject: s.cfg.Nats.Topic.Raw + ".>",
-	}
-	_, err := js.ConsumerInfo(s.cfg.Nats.Stream, consumercfg.Durable)
-	if errors.Is(err, nats.ErrConsumerNotFound) {
-		_, err = js.AddConsumer(s.cfg.Nats.Stream, consumercfg)
-		return err
+		FilterSubject: s.subject(),
 	}
-	_, err = js.UpdateConsumer(s.cfg.Nats.Stream, consumercfg)
-	return err
+	s.cfg.Nats.Delivery.Apply(cfg)
+
+	return stream.EnsureConsumer(js, s.cfg.Nats.Stream, cfg)
 }
 
-// Ensure we have a JetStream set up for deduplication on the producer.
+// Ensure the stream we consume from exists, it's owned by the irc-reader, so we only create it if it's missing.
 // Keep in mind, each message published to this stream needs to have the Nats-Msg-Id header set for deduplication.
 func (s *Service) ensureStream(js nats.JetStreamContext) error {
 	cfg := &nats.StreamConfig{
-		Name:      s.cfg.Nats.Stream,
-		Subjects:  []string{s.cfg.Nats.Topic.Raw + ".>"},
-		MaxAge:    1 * time.Hour,
-		Retention: nats.InterestPolicy,
-		Discard:   nats.DiscardNew,
-		// TODO: 0 seconds sets this to default value (2 min), find optimal value for our case
+		Name:       s.cfg.Nats.Stream,
+		Subjects:   s.streamSubjects(),
+		MaxAge:     1 * time.Hour,
+		Retention:  nats.InterestPolicy,
+		Discard:    nats.DiscardOld,
 		Duplicates: 1 * time.Minute,
 	}
-
-	_, err := js.StreamInfo(cfg.Name)
-	if errors.Is(err, nats.ErrStreamNotFound) {
-		_, err = js.AddStream(cfg)
+	err := s.cfg.Nats.Limits.Apply(cfg)
+	if err != nil {
 		return err
 	}
-	_, err = js.UpdateStream(cfg)
 
-	return err
+	return stream.Ensure(js, cfg, false)
+}
+
+// subject returns the subject we consume messages from, normalized messages are preferred if configured
+func (s *Service) subject() string {
+	if s.cfg.Nats.Topic.Normalized != "" {
+		return s.cfg.Nats.Topic.Normalized + ".privmsg.>"
+	}
+	// other IRC commands are published to the same stream, we only count emotes in chat messages
+	return s.cfg.Nats.Topic.Raw + ".privmsg.>"
+}
+
+// streamSubjects returns the subjects of the stream, these have to match the irc-reader config.
+// The stream isn't updated when they don't, but consuming fails if our subject isn't in the stream.
+func (s *Service) streamSubjects() []string {
+	subjects := []string{s.cfg.Nats.Topic.Raw + ".>"}
+	if s.cfg.Nats.Topic.Normalized != "" {
+		subjects = append(subjects, s.cfg.Nats.Topic.Normalized+".>")
+	}
+	return subjects
 }
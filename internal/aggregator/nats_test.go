@@ -0,0 +1,149 @@
+package aggregator
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+
+	"github.com/seventv/7tv-bot/internal/aggregator/config"
+)
+
+// runServer starts an embedded nats-server with JetStream enabled
+func runServer(tb testing.TB) *server.Server {
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  tb.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		tb.Fatal("nats-server not ready")
+	}
+	tb.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func newTestService(url string, batch int) *Service {
+	cfg := &config.Config{Maxworkers: 6}
+	cfg.Nats.URL = url
+	cfg.Nats.Stream = "twitchIRC"
+	cfg.Nats.Consumer = "stats-aggregator"
+	cfg.Nats.Topic.Raw = "irc.raw.twitch"
+	cfg.Nats.Fetch.Batch = batch
+	cfg.Nats.Fetch.MaxWait = 100 * time.Millisecond
+	return New(cfg)
+}
+
+func publishMessages(tb testing.TB, url string, n int) {
+	nc, err := nats.Connect(url)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream(nats.PublishAsyncMaxPending(1000))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		_, err = js.PublishAsync("irc.raw.twitch.privmsg.forsen", []byte("PRIVMSG #forsen :forsenE "+strconv.Itoa(i)))
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+	select {
+	case <-js.PublishAsyncComplete():
+	case <-time.After(30 * time.Second):
+		tb.Fatal("publishing timed out")
+	}
+}
+
+// consumeAll consumes until n messages were handled, and returns how many were handled in total
+func consumeAll(tb testing.TB, s *Service, n int64, cb func(msg *nats.Msg) error) int64 {
+	sub, err := s.subscribe()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer s.nc.Close()
+
+	var handled atomic.Int64
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.consume(ctx, sub, func(msg *nats.Msg) error {
+			err := cb(msg)
+			if err == nil && handled.Add(1) == n {
+				cancel()
+			}
+			return err
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		cancel()
+		tb.Fatalf("consumed %v of %v messages before timing out", handled.Load(), n)
+	}
+	return handled.Load()
+}
+
+func TestService_consume(t *testing.T) {
+	srv := runServer(t)
+	s := newTestService(srv.ClientURL(), 10)
+
+	// create the stream & consumer before publishing
+	_, err := s.subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nc.Close()
+
+	publishMessages(t, srv.ClientURL(), 100)
+
+	// fail every message once, they should all be redelivered
+	failed := make(map[string]bool)
+	mx := sync.Mutex{}
+	got := consumeAll(t, s, 100, func(msg *nats.Msg) error {
+		mx.Lock()
+		defer mx.Unlock()
+		if !failed[string(msg.Data)] {
+			failed[string(msg.Data)] = true
+			return context.DeadlineExceeded
+		}
+		return msg.Ack()
+	})
+	if got != 100 {
+		t.Errorf("consume() handled %v messages, want 100", got)
+	}
+}
+
+func BenchmarkService_consume(b *testing.B) {
+	for _, batch := range []int{1, 10, 100, 500} {
+		b.Run("batch="+strconv.Itoa(batch), func(b *testing.B) {
+			srv := runServer(b)
+			s := newTestService(srv.ClientURL(), batch)
+			_, err := s.subscribe()
+			if err != nil {
+				b.Fatal(err)
+			}
+			s.nc.Close()
+			publishMessages(b, srv.ClientURL(), b.N)
+
+			b.ResetTimer()
+			consumeAll(b, s, int64(b.N), func(msg *nats.Msg) error { return msg.Ack() })
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/types"
+	"github.com/seventv/7tv-bot/pkg/usage"
+)
+
+// realtime accumulates the emote usage per channel, and publishes it to core NATS every interval for live displays.
+// The usage isn't persisted or deduplicated like the counts, so it's best effort; redelivered messages aren't published again.
+type realtime struct {
+	nc       *nats.Conn
+	prefix   string
+	interval time.Duration
+
+	mx sync.Mutex
+	// counts holds the usage per channel & emote since the current interval started
+	since  time.Time
+	counts map[string]map[primitive.ObjectID]*types.CountedEmote
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newRealtime(nc *nats.Conn, prefix string, interval time.Duration) *realtime {
+	return &realtime{
+		nc:       nc,
+		prefix:   prefix,
+		interval: interval,
+		since:    time.Now().UTC(),
+		counts:   make(map[string]map[primitive.ObjectID]*types.CountedEmote),
+	}
+}
+
+// add adds the counted emotes of a message in the channel to the current interval
+func (r *realtime) add(channelID string, counted []types.CountedEmote) {
+	if len(counted) == 0 {
+		return
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	emotes, ok := r.counts[channelID]
+	if !ok {
+		emotes = make(map[primitive.ObjectID]*types.CountedEmote)
+		r.counts[channelID] = emotes
+	}
+	for _, emote := range counted {
+		if existing, ok := emotes[emote.Emote.EmoteID]; ok {
+			existing.Count += emote.Count
+			continue
+		}
+		emote := emote
+		emotes[emote.Emote.EmoteID] = &emote
+	}
+}
+
+func (r *realtime) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				r.publish(r.take(now.UTC()))
+			}
+		}
+	}()
+}
+
+func (r *realtime) stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
+
+// take returns the usage of every channel & all channels since the last interval, and starts the next interval at end
+func (r *realtime) take(end time.Time) []usage.Usage {
+	r.mx.Lock()
+	counts := r.counts
+	start := r.since
+	r.counts = make(map[string]map[primitive.ObjectID]*types.CountedEmote)
+	r.since = end
+	r.mx.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	global := make(map[primitive.ObjectID]*types.CountedEmote)
+	result := make([]usage.Usage, 0, len(counts)+1)
+	for channelID, emotes := range counts {
+		for id, emote := range emotes {
+			if existing, ok := global[id]; ok {
+				existing.Count += emote.Count
+				continue
+			}
+			global[id] = &types.CountedEmote{Count: emote.Count, Emote: emote.Emote}
+		}
+		// messages without a channel only count towards the global usage
+		if channelID == "" {
+			continue
+		}
+		result = append(result, newUsage(channelID, start, end, emotes))
+	}
+	return append(result, newUsage("", start, end, global))
+}
+
+func newUsage(channelID string, start, end time.Time, emotes map[primitive.ObjectID]*types.CountedEmote) usage.Usage {
+	u := usage.Usage{
+		Version:   usage.Version,
+		ChannelID: channelID,
+		Start:     start,
+		End:       end,
+		Emotes:    make([]usage.Emote, 0, len(emotes)),
+	}
+	for _, emote := range emotes {
+		u.Emotes = append(u.Emotes, usage.Emote{
+			ID:    emote.Emote.EmoteID.Hex(),
+			Name:  emote.Emote.Name,
+			URL:   emote.Emote.URL,
+			Count: emote.Count,
+		})
+	}
+	sort.Slice(u.Emotes, func(i, j int) bool {
+		if u.Emotes[i].Count == u.Emotes[j].Count {
+			return u.Emotes[i].ID < u.Emotes[j].ID
+		}
+		return u.Emotes[i].Count > u.Emotes[j].Count
+	})
+	return u
+}
+
+func (r *realtime) publish(usages []usage.Usage) {
+	for _, u := range usages {
+		data, err := json.Marshal(u)
+		if err != nil {
+			zap.S().Errorw("failed to marshal emote usage", "error", err)
+			continue
+		}
+		err = r.nc.Publish(usage.Subject(r.prefix, u.ChannelID), data)
+		if err != nil {
+			zap.S().Errorw("failed to publish emote usage", "error", err, "channel", u.ChannelID)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/seventv/7tv-bot/pkg/types"
+	"github.com/seventv/7tv-bot/pkg/usage"
+)
+
+func Test_realtime_publish(t *testing.T) {
+	srv := runServer(t)
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	sub, err := nc.SubscribeSync("emotes.usage.>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forsenE := types.Emote{Name: "forsenE", EmoteID: primitive.NewObjectID()}
+	okayeg := types.Emote{Name: "okayeg", EmoteID: primitive.NewObjectID()}
+	r := newRealtime(nc, "emotes.usage", time.Second)
+	r.add("22484632", []types.CountedEmote{{Count: 2, Emote: forsenE}})
+	r.add("22484632", []types.CountedEmote{{Count: 1, Emote: forsenE}, {Count: 1, Emote: okayeg}})
+	r.add("71092938", []types.CountedEmote{{Count: 4, Emote: okayeg}})
+	r.add("", []types.CountedEmote{{Count: 1, Emote: okayeg}})
+	r.publish(r.take(time.Now().UTC()))
+
+	got := make(map[string]usage.Usage)
+	for i := 0; i < 3; i++ {
+		msg, err := sub.NextMsg(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := usage.Usage{}
+		err = json.Unmarshal(msg.Data, &u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := usage.Subject("emotes.usage", u.ChannelID); msg.Subject != want {
+			t.Errorf("published usage of %q to %v, want %v", u.ChannelID, msg.Subject, want)
+		}
+		got[msg.Subject] = u
+	}
+	if _, err = sub.NextMsg(100 * time.Millisecond); err == nil {
+		t.Error("published more than the channels with usage & the global usage")
+	}
+
+	tests := []struct {
+		subject string
+		want    []usage.Emote
+	}{
+		{
+			subject: "emotes.usage.22484632",
+			want:    []usage.Emote{{Name: "forsenE", Count: 3}, {Name: "okayeg", Count: 1}},
+		},
+		{
+			subject: "emotes.usage.71092938",
+			want:    []usage.Emote{{Name: "okayeg", Count: 4}},
+		},
+		{
+			// most used first, messages without a channel are included
+			subject: "emotes.usage.global",
+			want:    []usage.Emote{{Name: "okayeg", Count: 6}, {Name: "forsenE", Count: 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			emotes := got[tt.subject].Emotes
+			if len(emotes) != len(tt.want) {
+				t.Fatalf("usage = %+v, want %+v", emotes, tt.want)
+			}
+			for i, want := range tt.want {
+				if emotes[i].Name != want.Name || emotes[i].Count != want.Count {
+					t.Errorf("usage = %+v, want %+v", emotes, tt.want)
+				}
+			}
+		})
+	}
+
+	// the next interval starts empty
+	if usages := r.take(time.Now().UTC()); len(usages) != 0 {
+		t.Errorf("take() = %v after taking the usage, want nothing", usages)
+	}
+}
@@ -0,0 +1,149 @@
+package aggregator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	emotedb "github.com/seventv/7tv-bot/pkg/database/emotes"
+)
+
+// rollup aggregates the minute buckets into hour buckets, and the hour buckets into day buckets every interval.
+// The current & previous bucket are recomputed on every run, older buckets only if the counter flushed counts into them since,
+// e.g. while catching up on the stream after downtime.
+type rollup struct {
+	interval  time.Duration
+	retention retention
+
+	mx sync.Mutex
+	// oldest is the oldest minute bucket flushed since the last successful rollup
+	oldest time.Time
+	// complete is the end of the last hour bucket that was rolled up after it ended
+	complete time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newRollup(interval time.Duration, retention retention) *rollup {
+	return &rollup{
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// flushed records the oldest minute bucket of a flush, so its hour & day are rolled up again
+func (r *rollup) flushed(oldest time.Time) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.oldest.IsZero() || oldest.Before(r.oldest) {
+		r.oldest = oldest
+	}
+}
+
+func (r *rollup) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			r.run(ctx, time.Now().UTC())
+		}
+	}()
+}
+
+func (r *rollup) stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	<-r.done
+}
+
+func (r *rollup) run(ctx context.Context, now time.Time) {
+	r.mx.Lock()
+	oldest := r.oldest
+	r.oldest = time.Time{}
+	r.mx.Unlock()
+
+	hours := rollupSince(oldest, now, r.retention.minute, emotedb.Hour)
+	err := emotedb.Rollup(ctx, emotedb.Minute, emotedb.Hour, hours)
+	if err == nil {
+		err = emotedb.Rollup(ctx, emotedb.Hour, emotedb.Day, rollupSince(hours, now, r.retention.hour, emotedb.Day))
+	}
+	if err != nil {
+		zap.S().Errorw("failed to roll up emote usage buckets", "error", err)
+		// try again from the same bucket next time
+		if !oldest.IsZero() {
+			r.flushed(oldest)
+		}
+		return
+	}
+	r.mx.Lock()
+	r.complete = now.Truncate(emotedb.Hour.Duration())
+	r.mx.Unlock()
+	zap.S().Debugw("rolled up emote usage buckets", "since", hours)
+}
+
+// rolledUp returns the end of the last hour bucket that was fully rolled up, zero before the first successful rollup
+func (r *rollup) rolledUp() time.Time {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.complete
+}
+
+// rollupSince returns the start of the oldest bucket of granularity to that's rolled up. That's the previous bucket,
+// so counts flushed after it ended are included, or the bucket of oldest if that's older.
+// Buckets the source granularity isn't fully retained for anymore are skipped, so they keep the counts they were rolled up with.
+func rollupSince(oldest, now time.Time, retention time.Duration, to emotedb.Granularity) time.Time {
+	size := to.Duration()
+	since := now.Truncate(size).Add(-size)
+	if !oldest.IsZero() && oldest.Before(since) {
+		since = oldest.Truncate(size)
+	}
+	if retention > 0 {
+		// the first bucket that starts after the source buckets started expiring
+		retained := now.Add(-retention).Truncate(size).Add(size)
+		if since.Before(retained) {
+			since = retained
+		}
+	}
+	return since
+}
+
+type retention struct {
+	minute time.Duration
+	hour   time.Duration
+	day    time.Duration
+}
+
+// bucketOptions returns the configured rollup interval & retention per granularity, or their defaults
+func (s *Service) bucketOptions() (interval time.Duration, ret retention) {
+	interval = s.cfg.Buckets.RollupInterval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	ret = retention{
+		minute: s.cfg.Buckets.Retention.Minute,
+		hour:   s.cfg.Buckets.Retention.Hour,
+		day:    s.cfg.Buckets.Retention.Day,
+	}
+	if ret.minute <= 0 {
+		ret.minute = 48 * time.Hour
+	}
+	if ret.hour <= 0 {
+		ret.hour = 90 * 24 * time.Hour
+	}
+	return interval, ret
+}
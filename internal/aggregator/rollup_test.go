@@ -0,0 +1,58 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	emotedb "github.com/seventv/7tv-bot/pkg/database/emotes"
+)
+
+func Test_rollupSince(t *testing.T) {
+	now := time.Date(2023, 7, 31, 15, 1, 38, 0, time.UTC)
+	tests := []struct {
+		name      string
+		oldest    time.Time
+		retention time.Duration
+		to        emotedb.Granularity
+		want      time.Time
+	}{
+		{
+			name: "previous hour",
+			to:   emotedb.Hour,
+			want: time.Date(2023, 7, 31, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "recent flush",
+			oldest: time.Date(2023, 7, 31, 15, 1, 0, 0, time.UTC),
+			to:     emotedb.Hour,
+			want:   time.Date(2023, 7, 31, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "flush after catching up",
+			oldest:    time.Date(2023, 7, 31, 9, 42, 0, 0, time.UTC),
+			retention: 48 * time.Hour,
+			to:        emotedb.Hour,
+			want:      time.Date(2023, 7, 31, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "flush older than retention",
+			oldest:    time.Date(2023, 7, 28, 9, 42, 0, 0, time.UTC),
+			retention: 48 * time.Hour,
+			to:        emotedb.Hour,
+			want:      time.Date(2023, 7, 29, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "previous day",
+			oldest: time.Date(2023, 7, 31, 14, 0, 0, 0, time.UTC),
+			to:     emotedb.Day,
+			want:   time.Date(2023, 7, 30, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rollupSince(tt.oldest, now, tt.retention, tt.to); !got.Equal(tt.want) {
+				t.Errorf("rollupSince() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,13 @@ package aggregator
 
 import (
 	"context"
+	"time"
 
+	"github.com/nats-io/nats.go"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
 
 	"github.com/seventv/7tv-bot/internal/aggregator/config"
 	"github.com/seventv/7tv-bot/pkg/database"
@@ -13,6 +17,19 @@ import (
 
 type Service struct {
 	cfg *config.Config
+	nc  *nats.Conn
+	// counter accumulates emote counts until they're flushed to the database
+	counter *counter
+	// rollup aggregates the minute buckets written by the counter into hour & day buckets
+	rollup *rollup
+	// trending ranks the trending emotes from the buckets, nil if it's disabled
+	trending *trending
+	// realtime publishes the live emote usage to NATS, nil if it's disabled
+	realtime *realtime
+
+	// cancel stops consuming messages, done is closed once the workers are finished
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 func New(cfg *config.Config) *Service {
@@ -37,8 +54,133 @@ func (s *Service) Init() error {
 			{Keys: bson.D{{"flags", -1}, {"count", -1}}},
 		},
 	)
-	emotedb.SetCollections(coll)
+	// keys of counted messages expire once they can't be redelivered anymore
+	processed := database.EnsureCollection(
+		s.cfg.Mongo.Collection+"-processed",
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{"created_at", 1}},
+				Options: options.Index().SetExpireAfterSeconds(int32(s.processedRetention().Seconds())),
+			},
+		},
+	)
+	// one document per emote per channel, sorted by count for the top emotes of a channel
+	channels := database.EnsureCollection(
+		s.cfg.Mongo.Collection+"-channels",
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{"channel_id", 1}, {"emote_id", 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{"channel_id", 1}, {"count", -1}}},
+		},
+	)
+	// chatters are counted across all channels, or per channel, depending on the config
+	users := database.EnsureCollection(
+		s.cfg.Mongo.Collection+"-users",
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{"user_id", 1}, {"channel_id", 1}, {"emote_id", 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{"user_id", 1}, {"count", -1}}},
+		},
+	)
+	interval, retention := s.bucketOptions()
+	emotedb.SetCollections(emotedb.Collections{
+		GlobalStats:  coll,
+		ChannelStats: channels,
+		Processed:    processed,
+		UserStats:    users,
+		OptOuts:      database.EnsureCollection(s.cfg.Mongo.Collection+"-optouts", nil),
+		Trending:     database.EnsureCollection(s.cfg.Mongo.Collection+"-trending", nil),
+		Minutely:     s.ensureBuckets(emotedb.Minute, retention.minute),
+		Hourly:       s.ensureBuckets(emotedb.Hour, retention.hour),
+		Daily:        s.ensureBuckets(emotedb.Day, retention.day),
+	})
 
 	initCache()
-	return s.subscribe(context.TODO(), s.handleMessage)
+
+	s.rollup = newRollup(interval, retention)
+	s.rollup.start()
+
+	if s.cfg.Trending.Enabled {
+		s.trending = newTrending(s.trendingOptions(), s.rollup.rolledUp)
+		s.trending.start()
+	}
+
+	flush := s.flushOptions()
+	s.counter = newCounter(flush.interval, flush.maxMessages)
+	s.counter.users = userOptions{
+		enabled:    s.cfg.Users.Enabled,
+		perChannel: s.cfg.Users.PerChannel,
+	}
+	s.counter.onFlush = s.rollup.flushed
+	s.counter.start()
+
+	sub, err := s.subscribe()
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.Nats.Usage.Subject != "" {
+		interval := s.cfg.Nats.Usage.Interval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		s.realtime = newRealtime(s.nc, s.cfg.Nats.Usage.Subject, interval)
+		s.realtime.start()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.consume(ctx, sub, s.handleMessage)
+	}()
+	return nil
+}
+
+// Shutdown stops fetching messages, and waits for the messages that were already fetched to be handled
+func (s *Service) Shutdown() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	if s.realtime != nil {
+		s.realtime.stop()
+	}
+	// flush the counts of the messages we handled, before the connection is closed
+	s.counter.stop()
+	s.rollup.stop()
+	if s.trending != nil {
+		s.trending.stop()
+	}
+
+	err := s.nc.Drain()
+	if err != nil {
+		zap.S().Errorw("failed to drain NATS connection", "error", err)
+	}
+}
+
+// ensureBuckets creates the collection of the usage buckets of a granularity, the buckets expire after retention unless it's 0
+func (s *Service) ensureBuckets(granularity emotedb.Granularity, retention time.Duration) *mongo.Collection {
+	expiry := options.Index()
+	if retention > 0 {
+		expiry.SetExpireAfterSeconds(int32(retention.Seconds()))
+	}
+	return database.EnsureCollection(
+		s.cfg.Mongo.Collection+"-"+granularity.String(),
+		[]mongo.IndexModel{
+			// rollups merge on these fields, so they must be unique
+			{
+				Keys:    bson.D{{"emote_id", 1}, {"channel_id", 1}, {"bucket", 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{"channel_id", 1}, {"bucket", 1}}},
+			{Keys: bson.D{{"bucket", 1}}, Options: expiry},
+		},
+	)
 }
@@ -0,0 +1,214 @@
+package aggregator
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+
+	emotedb "github.com/seventv/7tv-bot/pkg/database/emotes"
+)
+
+// trending periodically ranks the emotes that are used more than usual, globally & per channel.
+// The usage in the recent window is compared to the usage expected from the hourly buckets of the baseline before it,
+// every aggregator computes the same lists, so running multiple aggregators only repeats the work.
+type trending struct {
+	opts trendingOptions
+	// rolledUp returns the end of the last hour bucket that was fully rolled up, the baseline doesn't go past it
+	rolledUp func() time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type trendingOptions struct {
+	interval time.Duration
+	window   time.Duration
+	baseline time.Duration
+	minCount int
+	limit    int
+}
+
+func newTrending(opts trendingOptions, rolledUp func() time.Time) *trending {
+	return &trending{opts: opts, rolledUp: rolledUp}
+}
+
+func (t *trending) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.done = make(chan struct{})
+
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(t.opts.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := t.run(ctx, time.Now().UTC())
+			if err != nil {
+				zap.S().Errorw("failed to compute trending emotes", "error", err)
+			}
+		}
+	}()
+}
+
+func (t *trending) stop() {
+	if t.cancel == nil {
+		return
+	}
+	t.cancel()
+	<-t.done
+}
+
+func (t *trending) run(ctx context.Context, now time.Time) error {
+	start := now.Add(-t.opts.window)
+	recent, err := emotedb.RangeCounts(ctx, emotedb.Minute, start, now)
+	if err != nil {
+		return err
+	}
+	from, to := baselineRange(start, t.rolledUp(), t.opts.baseline)
+	if !to.After(from) {
+		zap.S().Debugw("skipped trending emotes, no hour buckets were rolled up yet", "from", from)
+		return nil
+	}
+	baseline, err := emotedb.RangeCounts(ctx, emotedb.Hour, from, to)
+	if err != nil {
+		return err
+	}
+
+	// the expected usage is scaled by the baseline that was actually covered
+	ratio := t.opts.window.Hours() / to.Sub(from).Hours()
+	ranked := rankTrending(recent, baseline, ratio, t.opts.minCount, t.opts.limit)
+
+	// fill in the emote data from the global stats
+	var ids []primitive.ObjectID
+	seen := make(map[primitive.ObjectID]bool)
+	for _, list := range ranked {
+		for _, emote := range list.Emotes {
+			if !seen[emote.EmoteID] {
+				seen[emote.EmoteID] = true
+				ids = append(ids, emote.EmoteID)
+			}
+		}
+	}
+	emotes, err := emotedb.GetEmotes(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, list := range ranked {
+		for i, emote := range list.Emotes {
+			data := emotes[emote.EmoteID]
+			list.Emotes[i].Name = data.Name
+			list.Emotes[i].Flags = data.Flags
+			list.Emotes[i].URL = data.URL
+		}
+	}
+
+	zap.S().Debugw("computed trending emotes", "lists", len(ranked))
+	return emotedb.SaveTrending(ctx, ranked, now)
+}
+
+// baselineRange returns the hour buckets the baseline before the recent window starting at start is read from.
+// It ends at the hour the recent window starts in, so the recent usage isn't part of it,
+// or at rolledUp if the hours after it weren't fully rolled up yet, so their partial counts don't understate the baseline.
+func baselineRange(start, rolledUp time.Time, baseline time.Duration) (from, to time.Time) {
+	from = start.Add(-baseline).Truncate(time.Hour)
+	to = start.Truncate(time.Hour)
+	if rolledUp.Before(to) {
+		to = rolledUp
+	}
+	return from, to
+}
+
+// rankTrending ranks the emotes by how much more they were used in the recent window than expected from the baseline,
+// globally & per channel. ratio is the length of the recent window relative to the baseline.
+// The score is a z-score assuming the usage is Poisson distributed, so an emote used 10 times instead of once
+// ranks below one used 1000 times instead of 500. Emotes used less than minCount times in the recent window are left out.
+func rankTrending(recent, baseline []emotedb.UsageBucket, ratio float64, minCount, limit int) []emotedb.TrendingList {
+	type key struct {
+		channelID string
+		emoteID   primitive.ObjectID
+	}
+	// the global list has an empty channel ID, messages without a channel only count towards it
+	add := func(counts map[key]int, bucket emotedb.UsageBucket) {
+		counts[key{"", bucket.EmoteID}] += bucket.Count
+		if bucket.ChannelID != "" {
+			counts[key{bucket.ChannelID, bucket.EmoteID}] += bucket.Count
+		}
+	}
+	recentCounts := make(map[key]int)
+	for _, bucket := range recent {
+		add(recentCounts, bucket)
+	}
+	baselineCounts := make(map[key]int)
+	for _, bucket := range baseline {
+		add(baselineCounts, bucket)
+	}
+
+	lists := make(map[string][]emotedb.TrendingEmote)
+	for k, count := range recentCounts {
+		if count < minCount {
+			continue
+		}
+		expected := float64(baselineCounts[k]) * ratio
+		score := (float64(count) - expected) / math.Sqrt(expected+1)
+		if score <= 0 {
+			continue
+		}
+		lists[k.channelID] = append(lists[k.channelID], emotedb.TrendingEmote{
+			EmoteID:  k.emoteID,
+			Recent:   count,
+			Expected: expected,
+			Score:    score,
+		})
+	}
+
+	result := make([]emotedb.TrendingList, 0, len(lists))
+	for channelID, emotes := range lists {
+		sort.Slice(emotes, func(i, j int) bool {
+			if emotes[i].Score == emotes[j].Score {
+				return emotes[i].EmoteID.Hex() < emotes[j].EmoteID.Hex()
+			}
+			return emotes[i].Score > emotes[j].Score
+		})
+		if len(emotes) > limit {
+			emotes = emotes[:limit]
+		}
+		result = append(result, emotedb.TrendingList{ChannelID: channelID, Emotes: emotes})
+	}
+	return result
+}
+
+// trendingOptions returns the configured trending windows & limits, or their defaults
+func (s *Service) trendingOptions() trendingOptions {
+	opts := trendingOptions{
+		interval: s.cfg.Trending.Interval,
+		window:   s.cfg.Trending.Window,
+		baseline: s.cfg.Trending.Baseline,
+		minCount: s.cfg.Trending.MinCount,
+		limit:    s.cfg.Trending.Limit,
+	}
+	if opts.interval <= 0 {
+		opts.interval = 5 * time.Minute
+	}
+	if opts.window <= 0 {
+		opts.window = time.Hour
+	}
+	if opts.baseline <= 0 {
+		opts.baseline = 24 * time.Hour
+	}
+	if opts.minCount < 1 {
+		opts.minCount = 10
+	}
+	if opts.limit < 1 {
+		opts.limit = 25
+	}
+	return opts
+}
@@ -0,0 +1,110 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	emotedb "github.com/seventv/7tv-bot/pkg/database/emotes"
+)
+
+func Test_rankTrending(t *testing.T) {
+	forsenE := primitive.NewObjectID()
+	okayeg := primitive.NewObjectID()
+	pepeLaugh := primitive.NewObjectID()
+	recent := []emotedb.UsageBucket{
+		{EmoteID: forsenE, ChannelID: "22484632", Count: 1000},
+		{EmoteID: okayeg, ChannelID: "22484632", Count: 10},
+		{EmoteID: okayeg, ChannelID: "71092938", Count: 10},
+		{EmoteID: pepeLaugh, ChannelID: "71092938", Count: 5},
+	}
+	// 24 hours of baseline for 1 hour of recent usage
+	baseline := []emotedb.UsageBucket{
+		{EmoteID: forsenE, ChannelID: "22484632", Count: 12000},
+		{EmoteID: okayeg, ChannelID: "22484632", Count: 24},
+		{EmoteID: pepeLaugh, ChannelID: "71092938", Count: 24},
+	}
+
+	ranked := make(map[string][]primitive.ObjectID)
+	for _, list := range rankTrending(recent, baseline, 1.0/24, 10, 2) {
+		for _, emote := range list.Emotes {
+			ranked[list.ChannelID] = append(ranked[list.ChannelID], emote.EmoteID)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		channel string
+		want    []primitive.ObjectID
+	}{
+		{
+			// forsenE is used twice as much as usual, which is more significant than okayeg going from 1 to 20 uses
+			name:    "global",
+			channel: "",
+			want:    []primitive.ObjectID{forsenE, okayeg},
+		},
+		{
+			name:    "channel",
+			channel: "22484632",
+			want:    []primitive.ObjectID{forsenE, okayeg},
+		},
+		{
+			// pepeLaugh is used less than the minimum count
+			name:    "below minimum count",
+			channel: "71092938",
+			want:    []primitive.ObjectID{okayeg},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ranked[tt.channel]
+			if len(got) != len(tt.want) {
+				t.Fatalf("rankTrending() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("rankTrending() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_baselineRange(t *testing.T) {
+	// the recent window of 1 hour starts at 14:40
+	start := time.Date(2023, 7, 31, 14, 40, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		rolledUp time.Time
+		wantFrom time.Time
+		wantTo   time.Time
+	}{
+		{
+			name:     "rolled up",
+			rolledUp: time.Date(2023, 7, 31, 15, 0, 0, 0, time.UTC),
+			wantFrom: time.Date(2023, 7, 30, 14, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2023, 7, 31, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			// the last rollup ran before 14:00, so the hour from 13:00 can be partial & is left out, the baseline covers 23 hours
+			name:     "partial hour",
+			rolledUp: time.Date(2023, 7, 31, 13, 0, 0, 0, time.UTC),
+			wantFrom: time.Date(2023, 7, 30, 14, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2023, 7, 31, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "not rolled up yet",
+			wantFrom: time.Date(2023, 7, 30, 14, 0, 0, 0, time.UTC),
+			wantTo:   time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := baselineRange(start, tt.rolledUp, 24*time.Hour)
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("baselineRange() = %v, %v, want %v, %v", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
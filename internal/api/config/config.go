@@ -1,10 +1,14 @@
 package config
 
 import (
+	"time"
+
 	"github.com/fsnotify/fsnotify"
 	"github.com/gookit/config/v2"
 	"github.com/gookit/config/v2/yaml"
 	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/stream"
 )
 
 // OnChange is called when a config change is detected, can be set during runtime
@@ -15,9 +19,24 @@ type Config struct {
 	Http     struct {
 		Port string
 	}
+	Kube struct {
+		Namespace   string
+		Oauthsecret string
+	}
 	Twitch struct {
-		User  string
-		Oauth string
+		User     string
+		Oauth    string
+		Clientid string
+		// Apptoken overrides the app access token stored in the kubernetes secret
+		Apptoken string
+	}
+	Helix struct {
+		// URL overrides the Helix API base URL, useful for a local mock
+		URL string
+	}
+	Renames struct {
+		// Interval between checks for username changes, 0 disables the check
+		Interval time.Duration
 	}
 	Mongo struct {
 		ConnectionString string
@@ -26,11 +45,24 @@ type Config struct {
 		Username         string
 		Password         string
 	}
+	// Stats is where the aggregator writes the emote stats, on the same MongoDB connection. The stats routes are disabled if Collection is unset.
+	Stats struct {
+		Database string
+		// Collection is the collection of the global stats, the other stats collections are prefixed with it
+		Collection string
+	}
 	Nats struct {
 		URL   string
 		Topic struct {
+			// Api is the subject prefix for channel changes, published to <api>.<channel id>
 			Api string
 		}
+		Changes struct {
+			// Stream is the JetStream stream holding the channel changes, shared with the IRC readers
+			Stream string
+			// Limits override the declared limits & storage of the stream, we own the stream so changes are applied on start
+			Limits stream.Config
+		}
 	}
 	Health struct {
 		Enabled bool
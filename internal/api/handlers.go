@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/nats-io/nats.go"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.uber.org/zap"
@@ -100,6 +99,15 @@ func (s *Server) postChannel(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "Bad request")
 		return
 	}
+
+	// fill in missing user ID or username from Twitch
+	resolved := []types.Channel{channel}
+	err = s.resolveChannels(r.Context(), resolved)
+	if err != nil {
+		zap.S().Errorw("resolve channel with helix", "error", err)
+	}
+	channel = resolved[0]
+
 	if !util.VerifyChannel(channel) {
 		writeError(w, http.StatusBadRequest, "Bad request")
 		return
@@ -117,14 +125,8 @@ func (s *Server) postChannel(w http.ResponseWriter, r *http.Request) {
 	}
 	writeError(w, http.StatusCreated, "Created")
 
-	// publish insert to NATS
-	header := make(nats.Header)
-	header.Add("OP", database.Insert)
-	s.nc.PublishMsg(&nats.Msg{
-		Data:    body,
-		Header:  header,
-		Subject: s.cfg.Nats.Topic.Api,
-	})
+	// publish insert to NATS, with the fields that could've been filled in by helix
+	s.publishChange(r.Context(), database.Insert, channel)
 }
 
 func (s *Server) deleteChannel(w http.ResponseWriter, r *http.Request) {
@@ -155,12 +157,6 @@ func (s *Server) deleteChannel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := json.Marshal(channel)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-
 	err = database.DeleteChannel(context.TODO(), int64(id))
 	if err != nil {
 		if errors.Is(err, database.ErrChannelNotFound) {
@@ -172,14 +168,8 @@ func (s *Server) deleteChannel(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Write([]byte("OK"))
 
-	// publish deleted channel ID to NATS
-	header := make(nats.Header)
-	header.Add("OP", database.Delete)
-	s.nc.PublishMsg(&nats.Msg{
-		Data:    data,
-		Header:  header,
-		Subject: s.cfg.Nats.Topic.Api,
-	})
+	// publish deleted channel to NATS
+	s.publishChange(r.Context(), database.Delete, *channel)
 }
 
 func (s *Server) postChannels(w http.ResponseWriter, r *http.Request) {
@@ -196,6 +186,13 @@ func (s *Server) postChannels(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "Bad request")
 		return
 	}
+
+	// fill in missing user IDs or usernames from Twitch
+	err = s.resolveChannels(r.Context(), channels)
+	if err != nil {
+		zap.S().Errorw("resolve channels with helix", "error", err)
+	}
+
 	for _, channel := range channels {
 		if channel.Flags == 0 {
 			channel.Flags = bitwise.Set(channel.Flags, bitwise.JOIN_IRC)
@@ -217,18 +214,7 @@ func (s *Server) postChannels(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// publish insert to NATS
-		data, err := json.Marshal(channel)
-		if err != nil {
-			zap.S().Errorw("NATS publish data marshal", "error", err)
-			continue
-		}
-		header := make(nats.Header)
-		header.Add("OP", database.Insert)
-		s.nc.PublishMsg(&nats.Msg{
-			Data:    data,
-			Header:  header,
-			Subject: s.cfg.Nats.Topic.Api,
-		})
+		s.publishChange(r.Context(), database.Insert, channel)
 	}
 	writeError(w, http.StatusCreated, "Created")
 }
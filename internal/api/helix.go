@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/helix"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+// initHelix sets up the Helix client, used to fill in missing user IDs & usernames.
+// The Helix client is optional, if no client ID is configured we skip it.
+func (s *Server) initHelix() error {
+	if s.cfg.Twitch.Clientid == "" {
+		return nil
+	}
+
+	token := s.cfg.Twitch.Apptoken
+	if token == "" {
+		err := s.kubeInit()
+		if err != nil {
+			return err
+		}
+		token, err = s.getAppTokenFromKubeSecret(context.Background())
+		// the oauth service writes the token asynchronously, on a first deploy we start without one & pick it up from the watch
+		if errors.Is(err, ErrNoAppToken) {
+			zap.S().Warn("no app access token in kubernetes secret yet, waiting for the oauth service to write it")
+		} else if err != nil {
+			return err
+		}
+	}
+
+	s.helix = helix.New(s.cfg.Twitch.Clientid, token)
+	if s.cfg.Helix.URL != "" {
+		s.helix.WithBaseURL(s.cfg.Helix.URL)
+	}
+
+	// keep the app access token up-to-date when the oauth service rotates it
+	if s.cfg.Twitch.Apptoken == "" && s.cfg.Kube.Oauthsecret != "" {
+		return s.watchKube(context.Background(), s.updateAppTokenFromKubeSecret)
+	}
+	return nil
+}
+
+// resolveChannels fills in the user ID or username of channels that only have one of the two set,
+// channels that can't be found on Twitch are left as-is
+func (s *Server) resolveChannels(ctx context.Context, channels []types.Channel) error {
+	if s.helix == nil {
+		return nil
+	}
+
+	var ids, logins []string
+	for _, channel := range channels {
+		if channel.ID == 0 && channel.Username != "" {
+			logins = append(logins, strings.ToLower(channel.Username))
+		}
+		if channel.ID != 0 && channel.Username == "" {
+			ids = append(ids, strconv.FormatInt(channel.ID, 10))
+		}
+	}
+
+	byLogin := make(map[string]helix.User)
+	if len(logins) > 0 {
+		users, err := s.helix.GetUsersByLogin(ctx, logins...)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			byLogin[user.Login] = user
+		}
+	}
+
+	byID := make(map[string]helix.User)
+	if len(ids) > 0 {
+		users, err := s.helix.GetUsersByID(ctx, ids...)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			byID[user.ID] = user
+		}
+	}
+
+	for i, channel := range channels {
+		if channel.ID == 0 {
+			user, ok := byLogin[strings.ToLower(channel.Username)]
+			if !ok {
+				continue
+			}
+			channels[i].ID, _ = strconv.ParseInt(user.ID, 10, 64)
+			continue
+		}
+		if channel.Username == "" {
+			user, ok := byID[strconv.FormatInt(channel.ID, 10)]
+			if !ok {
+				continue
+			}
+			channels[i].Username = user.Login
+		}
+	}
+	return nil
+}
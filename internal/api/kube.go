@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	ErrNoAppToken = errors.New("no app access token found in kubernetes secret data")
+	ErrNoSecret   = errors.New("no secret found")
+)
+
+func (s *Server) kubeInit() error {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return err
+	}
+	s.kube, err = kubernetes.NewForConfig(config)
+	return err
+}
+
+// watchKube calls cb whenever the oauth secret changes. The API server closes watches routinely,
+// so the watch is re-established from the last seen resource version until ctx is cancelled.
+func (s *Server) watchKube(ctx context.Context, cb func() error) error {
+	watcher, err := s.watchSecret(ctx, "")
+	if err != nil {
+		return err
+	}
+	go func() {
+		var resourceVersion string
+		backoff := time.Second
+		for {
+			for event := range watcher.ResultChan() {
+				switch event.Type {
+				case watch.Error:
+					// most likely the resource version expired, start over from the current state
+					resourceVersion = ""
+					continue
+				case watch.Bookmark:
+				default:
+					err := cb()
+					if err != nil {
+						zap.S().Infow("failed to update app access token from kubernetes secret", "error", err)
+					}
+				}
+				if secret, ok := event.Object.(*corev1.Secret); ok {
+					resourceVersion = secret.ResourceVersion
+				}
+			}
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				watcher, err = s.watchSecret(ctx, resourceVersion)
+				if err == nil {
+					backoff = time.Second
+					break
+				}
+				zap.S().Warnw("failed to re-establish kubernetes secret watch", "error", err, "backoff", backoff)
+				resourceVersion = ""
+				if backoff < time.Minute {
+					backoff *= 2
+				}
+			}
+			// changes between the watches without a resource version would be missed, so sync once
+			if resourceVersion == "" {
+				err = cb()
+				if err != nil {
+					zap.S().Infow("failed to update app access token from kubernetes secret", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *Server) watchSecret(ctx context.Context, resourceVersion string) (watch.Interface, error) {
+	opts := metav1.SingleObject(metav1.ObjectMeta{
+		Name: s.cfg.Kube.Oauthsecret,
+	})
+	opts.ResourceVersion = resourceVersion
+	return s.kube.CoreV1().Secrets(s.cfg.Kube.Namespace).Watch(ctx, opts)
+}
+
+func (s *Server) updateAppTokenFromKubeSecret() error {
+	token, err := s.getAppTokenFromKubeSecret(context.Background())
+	if err != nil {
+		return err
+	}
+	s.helix.UpdateToken(token)
+	zap.S().Info("updated app access token from kubernetes secret")
+	return nil
+}
+
+func (s *Server) getAppTokenFromKubeSecret(ctx context.Context) (string, error) {
+	secret, err := s.kube.CoreV1().Secrets(s.cfg.Kube.Namespace).Get(
+		ctx,
+		s.cfg.Kube.Oauthsecret,
+		metav1.GetOptions{},
+	)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", ErrNoSecret
+	}
+	data, ok := secret.Data["app-access-token"]
+	if !ok || len(data) == 0 {
+		return "", ErrNoAppToken
+	}
+	return string(data), nil
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/stream"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+// Ensure we have a JetStream for channel changes, so readers that were restarting or disconnected don't miss any.
+// Each channel publishes to its own subject, which keeps changes to a channel in order.
+func (s *Server) ensureStream(js nats.JetStreamContext) error {
+	cfg := &nats.StreamConfig{
+		Name:       s.cfg.Nats.Changes.Stream,
+		Subjects:   []string{s.cfg.Nats.Topic.Api + ".>"},
+		MaxAge:     7 * 24 * time.Hour,
+		Retention:  nats.LimitsPolicy,
+		Storage:    nats.FileStorage,
+		Duplicates: 1 * time.Minute,
+	}
+	err := s.cfg.Nats.Changes.Limits.Apply(cfg)
+	if err != nil {
+		return err
+	}
+
+	// we publish to this stream, so we own it
+	return stream.Ensure(js, cfg, true)
+}
+
+// publishChange publishes a change to the channel to its subject in the channel changes stream
+func (s *Server) publishChange(ctx context.Context, op string, channel types.Channel) {
+	data, err := json.Marshal(types.ChannelEvent{
+		Version:   types.ChannelEventVersion,
+		Op:        op,
+		Channel:   channel,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		zap.S().Errorw("NATS publish data marshal", "error", err)
+		return
+	}
+
+	_, err = s.jetStream.PublishMsg(&nats.Msg{
+		Subject: s.cfg.Nats.Topic.Api + "." + strconv.FormatInt(channel.ID, 10),
+		Data:    data,
+	}, nats.Context(ctx))
+	if err != nil {
+		zap.S().Errorw("failed to publish channel change", "error", err, "op", op, "channel", channel.Username)
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/internal/database"
+	"github.com/seventv/7tv-bot/pkg/helix"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+// UserResolver resolves Twitch user IDs to users, implemented by helix.Client
+type UserResolver interface {
+	GetUsersByID(ctx context.Context, ids ...string) ([]helix.User, error)
+}
+
+type rename struct {
+	channel     types.Channel
+	newUsername string
+}
+
+// watchRenames periodically checks all channels for username changes, until ctx is cancelled
+func (s *Server) watchRenames(ctx context.Context, resolver UserResolver, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := database.GetChannels(ctx, func(channels []types.Channel) {
+			renames, err := detectRenames(ctx, resolver, channels)
+			if err != nil {
+				zap.S().Errorw("failed to detect username changes", "error", err)
+				return
+			}
+			for _, r := range renames {
+				s.renameChannel(ctx, r)
+			}
+		}, helix.MaxBatchSize)
+		if err != nil {
+			zap.S().Errorw("failed to get channels for username changes", "error", err)
+		}
+	}
+}
+
+// detectRenames returns the channels of which the current login on Twitch differs from the stored username.
+// Channels that can't be found on Twitch (e.g. banned or deleted) are skipped.
+func detectRenames(ctx context.Context, resolver UserResolver, channels []types.Channel) ([]rename, error) {
+	ids := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		ids = append(ids, strconv.FormatInt(channel.ID, 10))
+	}
+
+	users, err := resolver.GetUsersByID(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	logins := make(map[string]string, len(users))
+	for _, user := range users {
+		logins[user.ID] = user.Login
+	}
+
+	var result []rename
+	for _, channel := range channels {
+		login, ok := logins[strconv.FormatInt(channel.ID, 10)]
+		// Helix logins are lowercase, channels can be stored with the display casing
+		if !ok || login == "" || strings.EqualFold(login, channel.Username) {
+			continue
+		}
+		result = append(result, rename{channel: channel, newUsername: login})
+	}
+	return result, nil
+}
+
+// renameChannel stores the new username & sends an update to the IRC readers, so they part the old name & join the new one
+func (s *Server) renameChannel(ctx context.Context, r rename) {
+	channel, err := database.RenameChannel(ctx, r.channel.ID, r.channel.Username, r.newUsername)
+	if err != nil {
+		zap.S().Errorw("failed to rename channel", "error", err, "channel", r.channel.Username)
+		return
+	}
+	zap.S().Infof("channel renamed from %v to %v", r.channel.Username, channel.Username)
+
+	// publish update to NATS
+	s.publishChange(ctx, database.Update, *channel)
+}
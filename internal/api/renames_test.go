@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/seventv/7tv-bot/pkg/helix"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+type fakeResolver struct {
+	users map[string]helix.User
+}
+
+func (f fakeResolver) GetUsersByID(_ context.Context, ids ...string) ([]helix.User, error) {
+	var result []helix.User
+	for _, id := range ids {
+		user, ok := f.users[id]
+		if !ok {
+			continue
+		}
+		result = append(result, user)
+	}
+	return result, nil
+}
+
+func Test_detectRenames(t *testing.T) {
+	resolver := fakeResolver{users: map[string]helix.User{
+		"26301881": {ID: "26301881", Login: "sodapoppin"},
+		"22484632": {ID: "22484632", Login: "forsen_new"},
+	}}
+
+	tests := []struct {
+		name     string
+		channels []types.Channel
+		want     []rename
+	}{
+		{
+			name: "Unchanged",
+			channels: []types.Channel{
+				{ID: 26301881, Username: "sodapoppin"},
+			},
+			want: nil,
+		},
+		{
+			name: "Renamed",
+			channels: []types.Channel{
+				{ID: 26301881, Username: "sodapoppin"},
+				{ID: 22484632, Username: "forsen"},
+			},
+			want: []rename{
+				{
+					channel:     types.Channel{ID: 22484632, Username: "forsen"},
+					newUsername: "forsen_new",
+				},
+			},
+		},
+		{
+			name: "DifferentCasing",
+			channels: []types.Channel{
+				{ID: 26301881, Username: "Sodapoppin"},
+			},
+			want: nil,
+		},
+		{
+			name: "NotFound",
+			channels: []types.Channel{
+				{ID: 1, Username: "banned"},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectRenames(context.Background(), resolver, tt.channels)
+			if err != nil {
+				t.Fatalf("detectRenames() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectRenames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
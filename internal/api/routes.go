@@ -45,5 +45,35 @@ func (s *Server) routes() []router.Route {
 			Handler:     s.postChannels,
 			Description: "Set channels using JSON array body.",
 		},
+		{
+			Pattern:     "/twitch/emotes/trending",
+			Method:      http.MethodGet,
+			Handler:     s.getTrending,
+			Description: "Get the trending emotes, globally or in the channel matching the channel (?channel=) url query parameter, at most ?limit= emotes.",
+		},
+		{
+			Pattern:     "/twitch/user/emotes",
+			Method:      http.MethodGet,
+			Handler:     s.getUserEmotes,
+			Description: "Get the most used emotes of the user matching the id (?id=) url query parameter, optionally in a channel (?channel=), at most ?limit= emotes.",
+		},
+		{
+			Pattern:     "/twitch/user/emotes",
+			Method:      http.MethodDelete,
+			Handler:     s.deleteUserEmotes,
+			Description: "Delete the emote stats of the user matching the id (?id=) url query parameter. Counts flushed at the same time can show up shortly after, unless the user opted out.",
+		},
+		{
+			Pattern:     "/twitch/user/optout",
+			Method:      http.MethodPost,
+			Handler:     s.postOptOut,
+			Description: "Stop counting the emotes of the user matching the id (?id=) url query parameter & delete their emote stats. The deletion is eventually consistent, counts flushed while opting out can show up shortly after.",
+		},
+		{
+			Pattern:     "/twitch/user/optout",
+			Method:      http.MethodDelete,
+			Handler:     s.deleteOptOut,
+			Description: "Count the emotes of the user matching the id (?id=) url query parameter again.",
+		},
 	}
 }
@@ -1,13 +1,16 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"sync"
 
 	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
 
 	"github.com/seventv/7tv-bot/internal/api/config"
+	"github.com/seventv/7tv-bot/pkg/helix"
 	"github.com/seventv/7tv-bot/pkg/router"
 )
 
@@ -16,6 +19,12 @@ type Server struct {
 	router *router.Router
 	wg     sync.WaitGroup
 	nc     *nats.Conn
+	// jetStream publishes channel changes to the stream the IRC readers consume
+	jetStream nats.JetStreamContext
+	kube      *kubernetes.Clientset
+	helix     *helix.Client
+	// stats is set once we're connected to the emote stats of the aggregator
+	stats bool
 }
 
 func New(cfg *config.Config) *Server {
@@ -34,6 +43,27 @@ func (s *Server) Init() error {
 	if err != nil {
 		zap.S().Fatal("failed to connect to NATS: ", err)
 	}
+	s.jetStream, err = s.nc.JetStream()
+	if err != nil {
+		zap.S().Fatal("failed to set up JetStream: ", err)
+	}
+	err = s.ensureStream(s.jetStream)
+	if err != nil {
+		zap.S().Fatal("failed to set up channel changes stream: ", err)
+	}
+
+	err = s.initHelix()
+	if err != nil {
+		zap.S().Fatal("failed to set up helix client: ", err)
+	}
+	if s.helix != nil && s.cfg.Renames.Interval > 0 {
+		go s.watchRenames(context.Background(), s.helix, s.cfg.Renames.Interval)
+	}
+
+	err = s.initStats()
+	if err != nil {
+		zap.S().Fatal("failed to connect to emote stats: ", err)
+	}
 
 	go func() {
 		if err := server.ListenAndServe(); err != nil {
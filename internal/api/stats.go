@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/database"
+	emotedb "github.com/seventv/7tv-bot/pkg/database/emotes"
+)
+
+const (
+	defaultStatsLimit = 10
+	maxStatsLimit     = 100
+)
+
+// initStats connects to the emote stats written by the aggregator, if they're configured.
+// The collections are owned by the aggregator, so they aren't created here.
+func (s *Server) initStats() error {
+	if s.cfg.Stats.Collection == "" {
+		return nil
+	}
+	err := database.Connect(
+		s.cfg.Mongo.ConnectionString,
+		s.cfg.Mongo.Username,
+		s.cfg.Mongo.Password,
+		s.cfg.Stats.Database,
+	)
+	if err != nil {
+		return err
+	}
+
+	coll := s.cfg.Stats.Collection
+	emotedb.SetCollections(emotedb.Collections{
+		GlobalStats:  database.Collection(coll),
+		ChannelStats: database.Collection(coll + "-channels"),
+		UserStats:    database.Collection(coll + "-users"),
+		OptOuts:      database.Collection(coll + "-optouts"),
+		Trending:     database.Collection(coll + "-trending"),
+		Minutely:     database.Collection(coll + "-" + emotedb.Minute.String()),
+		Hourly:       database.Collection(coll + "-" + emotedb.Hour.String()),
+		Daily:        database.Collection(coll + "-" + emotedb.Day.String()),
+	})
+	s.stats = true
+	return nil
+}
+
+// userID returns the numeric user ID from the id url query parameter
+func userID(r *http.Request) (string, bool) {
+	id := r.URL.Query().Get("id")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// channelID returns the numeric channel ID from the optional channel url query parameter, empty if it's missing
+func channelID(r *http.Request) (string, bool) {
+	id := r.URL.Query().Get("channel")
+	if id == "" {
+		return "", true
+	}
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// limit returns the limit url query parameter, or the default limit if it's missing
+func limit(r *http.Request) (int64, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultStatsLimit, true
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 1 || limit > maxStatsLimit {
+		return 0, false
+	}
+	return limit, true
+}
+
+func (s *Server) getTrending(w http.ResponseWriter, r *http.Request) {
+	if !s.stats {
+		notImplemented(w, r)
+		return
+	}
+	channel, ok := channelID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+	limit, ok := limit(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+
+	trending, err := emotedb.GetTrending(r.Context(), channel)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// a 204 can't have a body
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		zap.S().Errorw("get trending emotes", "error", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if int64(len(trending.Emotes)) > limit {
+		trending.Emotes = trending.Emotes[:limit]
+	}
+
+	data, err := json.Marshal(trending)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.Write(data)
+}
+
+func (s *Server) getUserEmotes(w http.ResponseWriter, r *http.Request) {
+	if !s.stats {
+		notImplemented(w, r)
+		return
+	}
+	id, ok := userID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+	channel, ok := channelID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+	limit, ok := limit(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+
+	emotes, err := emotedb.TopUserEmotes(r.Context(), id, channel, limit)
+	if err != nil {
+		zap.S().Errorw("get user emotes", "error", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	data, err := json.Marshal(emotes)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.Write(data)
+}
+
+func (s *Server) deleteUserEmotes(w http.ResponseWriter, r *http.Request) {
+	if !s.stats {
+		notImplemented(w, r)
+		return
+	}
+	id, ok := userID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+
+	deleted, err := emotedb.DeleteUserEmotes(r.Context(), id)
+	if err != nil {
+		zap.S().Errorw("delete user emotes", "error", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	zap.S().Infow("deleted user emote stats", "user", id, "deleted", deleted)
+	w.Write([]byte("OK"))
+}
+
+func (s *Server) postOptOut(w http.ResponseWriter, r *http.Request) {
+	if !s.stats {
+		notImplemented(w, r)
+		return
+	}
+	id, ok := userID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+
+	deleted, err := emotedb.OptOut(r.Context(), id)
+	if err != nil {
+		zap.S().Errorw("opt out user", "error", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	zap.S().Infow("user opted out of emote stats", "user", id, "deleted", deleted)
+	writeError(w, http.StatusCreated, "Created")
+}
+
+func (s *Server) deleteOptOut(w http.ResponseWriter, r *http.Request) {
+	if !s.stats {
+		notImplemented(w, r)
+		return
+	}
+	id, ok := userID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+
+	err := emotedb.OptIn(r.Context(), id)
+	if err != nil {
+		zap.S().Errorw("opt in user", "error", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.Write([]byte("OK"))
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+// ChannelChange is a change to a channel, read from the change stream of the channels collection
+type ChannelChange struct {
+	// Operation is either Insert, Update or Delete
+	Operation string
+	Channel   types.Channel
+	// Partial is true for deletes where the change stream didn't include the channel, only possible without pre-images
+	Partial bool
+	// ResumeToken resumes the change stream right after this change
+	ResumeToken bson.Raw
+}
+
+type changeEvent struct {
+	OperationType            string         `bson:"operationType"`
+	FullDocument             *types.Channel `bson:"fullDocument"`
+	FullDocumentBeforeChange *types.Channel `bson:"fullDocumentBeforeChange"`
+}
+
+// resumeToken is a persisted change stream position
+type resumeToken struct {
+	Name      string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// EnablePreImages makes the change stream include deleted channels, requires MongoDB 6.0 or newer.
+// Without pre-images, deletes only contain the document ID.
+func EnablePreImages(ctx context.Context) error {
+	return db.RunCommand(ctx, bson.D{
+		{"collMod", collection.Name()},
+		{"changeStreamPreAndPostImages", bson.D{{"enabled", true}}},
+	}).Err()
+}
+
+// WatchChannels blocks, running the callback for every change to the channels collection until ctx is cancelled
+// or the change stream fails. The change stream starts after resumeToken, or at the current time if it's nil.
+func WatchChannels(ctx context.Context, resumeToken bson.Raw, cb func(ChannelChange)) error {
+	opts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+	if resumeToken != nil {
+		opts.SetResumeAfter(resumeToken)
+	}
+	pipeline := mongo.Pipeline{{{"$match", bson.D{
+		{"operationType", bson.D{{"$in", bson.A{"insert", "update", "replace", "delete"}}}},
+	}}}}
+
+	stream, err := collection.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		event := changeEvent{}
+		err = stream.Decode(&event)
+		if err != nil {
+			return err
+		}
+
+		change := ChannelChange{ResumeToken: stream.ResumeToken()}
+		switch event.OperationType {
+		case "insert":
+			change.Operation = Insert
+			change.Channel = *event.FullDocument
+		case "update", "replace":
+			// the channel was deleted before we looked it up, we'll get the delete next
+			if event.FullDocument == nil {
+				continue
+			}
+			change.Operation = Update
+			change.Channel = *event.FullDocument
+		case "delete":
+			change.Operation = Delete
+			if event.FullDocumentBeforeChange != nil {
+				change.Channel = *event.FullDocumentBeforeChange
+			} else {
+				change.Partial = true
+			}
+		}
+		cb(change)
+	}
+	return stream.Err()
+}
+
+// IsHistoryLost returns true if the error means the resume token is no longer in the oplog,
+// the change stream then has to be started without it
+func IsHistoryLost(err error) bool {
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	// ChangeStreamHistoryLost & ChangeStreamFatalError
+	return cmdErr.Code == 286 || cmdErr.Code == 280
+}
+
+// LoadResumeToken returns the persisted resume token of the change stream with the given name, nil if there is none
+func LoadResumeToken(ctx context.Context, name string) (bson.Raw, error) {
+	token := resumeToken{}
+	err := resumeTokens().FindOne(ctx, bson.D{{"_id", name}}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return token.Token, err
+}
+
+// SaveResumeToken persists the resume token of the change stream with the given name, a nil token removes it
+func SaveResumeToken(ctx context.Context, name string, token bson.Raw) error {
+	if token == nil {
+		_, err := resumeTokens().DeleteOne(ctx, bson.D{{"_id", name}})
+		return err
+	}
+	opts := options.Replace().SetUpsert(true)
+	_, err := resumeTokens().ReplaceOne(ctx, bson.D{{"_id", name}}, resumeToken{
+		Name:      name,
+		Token:     token,
+		UpdatedAt: time.Now(),
+	}, opts)
+	return err
+}
+
+func resumeTokens() *mongo.Collection {
+	return db.Collection(collection.Name() + "-resume-tokens")
+}
@@ -81,6 +81,33 @@ func UpsertChannel(ctx context.Context, channel types.Channel) error {
 	return err
 }
 
+// RenameChannel changes the username of a channel & appends the old username to its history,
+// returns ErrChannelNotFound if the channel doesn't exist or its username was changed in the meantime
+func RenameChannel(ctx context.Context, id int64, oldUsername, newUsername string) (*types.Channel, error) {
+	now := time.Now()
+	filter := bson.D{{"user_id", id}, {"username", oldUsername}}
+	update := bson.M{
+		"$set": bson.M{
+			"username":   newUsername,
+			"updated_at": now,
+		},
+		"$push": bson.M{
+			"username_history": types.UsernameChange{
+				Username:  oldUsername,
+				ChangedAt: now,
+			},
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	channel := &types.Channel{}
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(channel)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrChannelNotFound
+	}
+	return channel, err
+}
+
 // DeleteChannel deletes a channel by ID, returns an error if the channel ID was not found
 func DeleteChannel(ctx context.Context, id int64) error {
 	res, err := collection.DeleteOne(ctx, bson.D{{"user_id", id}})
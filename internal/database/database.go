@@ -57,5 +57,3 @@ func EnsureCollection(coll string) {
 
 	collection.Indexes().CreateMany(ctx, indexes)
 }
-
-// TODO: watch for changes on the collection, so we can join/leave channels when they get added or removed
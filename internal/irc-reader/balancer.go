@@ -0,0 +1,177 @@
+package irc_reader
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/internal/database"
+	"github.com/seventv/7tv-bot/pkg/sharding"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+// balancer holds the weight-balanced assignment of channels to readers.
+// The elected leader computes the assignment & stores it in redis, every reader joins & parts channels to converge on it.
+type balancer struct {
+	elector     *sharding.Elector
+	assignments *sharding.Assignments
+	tolerance   float64
+
+	mx         sync.RWMutex
+	assignment map[string]string
+	version    int64
+}
+
+// owners returns the reader the channel is assigned to & its replicas, channels that haven't been assigned yet
+// fall back to rendezvous hashing until the next assignment
+func (b *balancer) owners(key string, replicas int, members []string) []string {
+	b.mx.RLock()
+	assignment := b.assignment
+	b.mx.RUnlock()
+	return assignedOwners(assignment, members)(key, replicas)
+}
+
+// assignedOwners returns the owner lookup for a fixed assignment & member list
+func assignedOwners(assignment map[string]string, members []string) ownersFunc {
+	return func(key string, replicas int) []string {
+		owner, ok := assignment[key]
+		if !ok {
+			return sharding.Owners(key, members, replicas)
+		}
+		return withReplicas(owner, key, members, replicas)
+	}
+}
+
+func (c *Controller) initBalancer(ctx context.Context, client *redis.Client, changed <-chan struct{}) error {
+	key := c.cfg.Sharding.Key
+	c.balancer = &balancer{
+		elector:     sharding.NewElector(client, key+":leader", c.registry.ID(), c.cfg.Sharding.TTL),
+		assignments: sharding.NewAssignments(client, key+":assignment"),
+		tolerance:   c.cfg.Sharding.Tolerance,
+	}
+
+	// load the current assignment before we join any channels
+	assignment, version, err := c.balancer.assignments.Load(ctx)
+	if err != nil {
+		return err
+	}
+	c.balancer.assignment = assignment
+	c.balancer.version = version
+
+	go c.balancer.elector.Start(ctx, c.cfg.Sharding.Interval)
+	go c.coordinate(ctx, changed)
+	go c.watchAssignment(ctx)
+	return nil
+}
+
+// coordinate recomputes the assignment on membership changes & every rebalance interval, while we're the leader
+func (c *Controller) coordinate(ctx context.Context, changed <-chan struct{}) {
+	interval := c.cfg.Sharding.Rebalance
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-changed:
+		}
+		if !c.balancer.elector.IsLeader() {
+			continue
+		}
+		err := c.assign(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to assign channels", "error", err)
+		}
+	}
+}
+
+// assign computes a weight-balanced assignment of all channels across the live readers, and stores it if it changed
+func (c *Controller) assign(ctx context.Context) error {
+	members := c.registry.Members()
+	previous, _, err := c.balancer.assignments.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	var items []sharding.Item
+	err = database.GetChannels(ctx, func(channels []types.Channel) {
+		for _, channel := range channels {
+			key := strconv.FormatInt(channel.ID, 10)
+			items = append(items, sharding.Item{Key: key, Weight: channel.Weight})
+			// unassigned channels are read by their rendezvous owner, so start from there
+			if _, ok := previous[key]; !ok {
+				previous[key] = sharding.Owner(key, members)
+			}
+		}
+	}, 100)
+	if err != nil {
+		return err
+	}
+
+	assignment := sharding.Balance(items, members, previous, c.balancer.tolerance)
+	if sameAssignment(previous, assignment) {
+		return nil
+	}
+	zap.S().Infow("saving new channel assignment", "channels", len(assignment), "members", members)
+	return c.balancer.assignments.Save(ctx, assignment)
+}
+
+// watchAssignment checks the assignment version every heartbeat interval, and converges on the new assignment once it changed
+func (c *Controller) watchAssignment(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Sharding.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		version, err := c.balancer.assignments.Version(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to get assignment version", "error", err)
+			continue
+		}
+		c.balancer.mx.RLock()
+		current := c.balancer.version
+		c.balancer.mx.RUnlock()
+		if version == current {
+			continue
+		}
+
+		assignment, version, err := c.balancer.assignments.Load(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to load assignment", "error", err)
+			continue
+		}
+
+		c.balancer.mx.Lock()
+		previous := c.balancer.assignment
+		c.balancer.assignment = assignment
+		c.balancer.version = version
+		c.balancer.mx.Unlock()
+
+		members := c.registry.Members()
+		c.rebalance(ctx, assignedOwners(previous, members), assignedOwners(assignment, members))
+	}
+}
+
+func sameAssignment(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, owner := range a {
+		if b[key] != owner {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package irc_reader
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/internal/database"
+)
+
+// watchChangeStream blocks, joining, updating & parting channels as they change in the database until ctx is cancelled.
+// Unlike the NATS messages from the API, this picks up direct database edits, and resumes where it left off after a restart.
+func (c *Controller) watchChangeStream(ctx context.Context) {
+	name := c.cfg.Changestream.Name
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+
+	err := database.EnablePreImages(ctx)
+	if err != nil {
+		zap.S().Warnw("failed to enable change stream pre-images, deleted channels are parted by reconciliation", "error", err)
+	}
+
+	for {
+		token, err := database.LoadResumeToken(ctx, name)
+		if err != nil {
+			zap.S().Errorw("failed to load change stream resume token", "error", err)
+		}
+
+		err = database.WatchChannels(ctx, token, func(change database.ChannelChange) {
+			if change.Partial {
+				// we don't know which channel got deleted, so compare everything we read against the database
+				err := c.reconcile(ctx)
+				if err != nil {
+					zap.S().Errorw("failed to reconcile after channel delete", "error", err)
+				}
+			} else {
+				c.handleChange(change.Operation, change.Channel)
+			}
+
+			err := database.SaveResumeToken(ctx, name, change.ResumeToken)
+			if err != nil {
+				zap.S().Errorw("failed to save change stream resume token", "error", err)
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		if database.IsHistoryLost(err) {
+			// changes were missed, start from now & catch up through reconciliation
+			zap.S().Warnw("change stream history lost, restarting without resume token", "error", err)
+			err = database.SaveResumeToken(ctx, name, nil)
+			if err != nil {
+				zap.S().Errorw("failed to remove change stream resume token", "error", err)
+			}
+			err = c.reconcile(ctx)
+			if err != nil {
+				zap.S().Errorw("failed to reconcile after losing change stream history", "error", err)
+			}
+			continue
+		}
+
+		zap.S().Errorw("change stream closed, restarting", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/gookit/config/v2"
 	"github.com/gookit/config/v2/yaml"
 	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/stream"
 )
 
 // OnChange is called when a config change is detected, can be set during runtime
@@ -16,6 +18,45 @@ type Config struct {
 	LogLevel string
 	Replicas int
 
+	Sharding struct {
+		// Enabled assigns channels to the readers registered in redis with rendezvous hashing,
+		// instead of the user ID modulo Replicas. Channels of a reader that stops sending heartbeats are taken over by the others.
+		Enabled bool
+		// Key of the redis sorted set holding the registered readers
+		Key string
+		// TTL of a reader's lease, it's considered dead once its lease expires
+		TTL time.Duration
+		// Interval between heartbeats, must be lower than the TTL
+		Interval time.Duration
+		// Strategy is either "rendezvous" (default), or "balanced", where a leader-elected coordinator assigns channels by weight
+		Strategy string
+		// Rebalance is the interval at which the coordinator recomputes the balanced assignment
+		Rebalance time.Duration
+		// Tolerance is how far a reader can go above its fair share of the total weight before channels are moved off it, e.g. 0.1
+		Tolerance float64
+	}
+	Reconcile struct {
+		// Interval between reconciliations of the channels in the database & the joined channels, 0 disables reconciliation
+		Interval time.Duration
+	}
+	Changestream struct {
+		// Enabled reads channel changes from the MongoDB change stream instead of the NATS messages from the API,
+		// so direct database edits are picked up as well. Requires a replica set.
+		Enabled bool
+		// Name the resume token is stored under, defaults to the hostname
+		Name string
+	}
+	Handoff struct {
+		// Enabled hands our channels off to the other readers on shutdown, and waits for them to join before parting
+		Enabled bool
+		// Subject of the handoff requests, shared by all readers
+		Subject string
+		// Timeout is how long we wait for the other readers to confirm, must be lower than the termination grace period.
+		// Only as many channels as the join rate limit allows within the timeout are handed off, heaviest first.
+		Timeout time.Duration
+		// Hold is how long adopted channels are kept before channels that aren't assigned to us are parted again
+		Hold time.Duration
+	}
 	Kube struct {
 		Namespace   string
 		Oauthsecret string
@@ -34,8 +75,34 @@ type Config struct {
 		}
 	}
 	Twitch struct {
-		User  string
-		Oauth string
+		User     string
+		Oauth    string
+		Clientid string
+		// Apptoken overrides the app access token stored in the kubernetes secret
+		Apptoken string
+	}
+	Helix struct {
+		// URL overrides the Helix API base URL, useful for a local mock
+		URL string
+	}
+	Live struct {
+		// Enabled makes channels with a weight below the threshold only get joined while they're live
+		Enabled   bool
+		Threshold int
+		// Grace is how long we stay in a channel after it went offline
+		Grace time.Duration
+		// Source of the stream status, either "helix" (polling, uses the app access token)
+		// or "eventsub" (uses the user access token, limited to 150 channels per reader)
+		Source string
+		// Interval between polls of the helix source, defaults to 1 minute
+		Interval time.Duration
+	}
+	Eventsub struct {
+		// Enabled reads channels flagged with JOIN_EVENTSUB through EventSub channel.chat.message subscriptions,
+		// requires the user access token to have the user:read:chat scope. Limited to 300 channels per reader.
+		Enabled bool
+		// URL overrides the EventSub WebSocket URL, useful for a local test server
+		URL string
 	}
 	Mongo struct {
 		ConnectionString string
@@ -47,10 +114,42 @@ type Config struct {
 	Nats struct {
 		URL    string
 		Stream string
+		// Limits override the declared limits & storage of the stream, we own the stream so changes are applied on start.
+		// The deduplication window has to cover the delay between redundant readers.
+		Limits stream.Config
 		Topic  struct {
 			Raw string
+			// Api is the subject prefix of channel changes sent from the API, <api>.<channel id>
 			Api string
-			// provisions support for optional JSON or other parsed format output in the future
+			// Normalized publishes messages in the pkg/message schema on this subject, next to the raw IRC line.
+			// Leave empty to only publish raw messages.
+			Normalized string
+		}
+		Changes struct {
+			// Stream is the JetStream stream holding the channel changes, shared with the API
+			Stream string
+			// Consumer is the name of our durable consumer, defaults to the hostname. Must be unique per reader.
+			Consumer string
+		}
+		// Format of the normalized messages, either "json" or "protobuf"
+		Format string
+		// Commands are the IRC commands published to <raw>.<command>.<channel>, e.g. USERNOTICE or CLEARCHAT.
+		// Defaults to only PRIVMSG, JOIN & PART are only published for the bot user.
+		// Only commands with an id or tmi-sent-ts tag (PRIVMSG, USERNOTICE, CLEARCHAT & CLEARMSG) are deduplicated across replicas,
+		// JOIN, PART & ROOMSTATE are published once by every reader in the channel.
+		Commands []string
+	}
+	Publish struct {
+		// Queue is the amount of messages buffered in memory before they're spilled to disk, or dropped
+		Queue int
+		// Workers is the amount of goroutines publishing messages to NATS
+		Workers int
+		Spill   struct {
+			// Dir stores messages that couldn't be published while NATS is unavailable, they're replayed once it's back.
+			// Should be a persistent volume to survive restarts, leave empty to drop those messages instead.
+			Dir string
+			// MaxBytes limits the size of the spilled messages on disk, 0 means unlimited
+			MaxBytes int64
 		}
 	}
 	Health struct {
@@ -0,0 +1,197 @@
+package irc_reader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/eventsub"
+	"github.com/seventv/7tv-bot/pkg/irc"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+var ErrUserNotFound = errors.New("twitch user not found")
+
+// chatSource reads channels through EventSub channel.chat.message subscriptions,
+// messages are converted to IRC PRIVMSG lines, so they're published the same way as messages from IRC
+type chatSource struct {
+	client        *eventsub.Client
+	subscriptions *eventsub.Subscriptions
+	// userID of the bot user, needed in the subscription condition
+	userID string
+
+	onMessage func(msg *irc.Message, err error)
+	// cancel stops the client, so no messages are published after shutdown
+	cancel context.CancelFunc
+}
+
+func (c *Controller) initChat(ctx context.Context) error {
+	users, err := c.userHelix.GetUsersByLogin(ctx, c.cfg.Twitch.User)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return ErrUserNotFound
+	}
+
+	client := eventsub.New()
+	if c.cfg.Eventsub.URL != "" {
+		client.WithURL(c.cfg.Eventsub.URL)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.chat = &chatSource{
+		cancel:        cancel,
+		client:        client,
+		subscriptions: eventsub.NewSubscriptions(client, c.userHelix),
+		userID:        users[0].ID,
+		onMessage:     c.onMessage,
+	}
+	client.OnNotification(c.chat.handleNotification)
+	client.OnRevocation(func(subscription eventsub.Subscription) {
+		zap.S().Warnw("eventsub chat subscription revoked", "status", subscription.Status, "condition", subscription.Condition)
+	})
+
+	go func() {
+		err := client.Run(ctx, 5*time.Second)
+		if err != nil && ctx.Err() == nil {
+			zap.S().Errorw("eventsub chat source stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// stop disconnects from EventSub, the subscriptions are removed by Twitch once the session is gone
+func (s *chatSource) stop() {
+	s.cancel()
+}
+
+// add subscribes to the chat messages of the channel
+func (s *chatSource) add(ctx context.Context, channel types.Channel) error {
+	id := strconv.FormatInt(channel.ID, 10)
+	return s.subscriptions.Add(ctx, id, eventsub.Topic{
+		Type:    eventsub.ChannelChatMessage,
+		Version: "1",
+		Condition: map[string]string{
+			"broadcaster_user_id": id,
+			"user_id":             s.userID,
+		},
+	})
+}
+
+// remove deletes the chat message subscription of the channel
+func (s *chatSource) remove(ctx context.Context, channel types.Channel) error {
+	return s.subscriptions.Remove(ctx, strconv.FormatInt(channel.ID, 10))
+}
+
+// has returns true if we're subscribed to the chat messages of the channel
+func (s *chatSource) has(channel types.Channel) bool {
+	return s.subscriptions.Has(strconv.FormatInt(channel.ID, 10))
+}
+
+func (s *chatSource) handleNotification(notification *eventsub.NotificationPayload) {
+	if notification.Subscription.Type != eventsub.ChannelChatMessage {
+		return
+	}
+	event := &eventsub.ChatMessageEvent{}
+	err := json.Unmarshal(notification.Event, event)
+	if err != nil {
+		zap.S().Errorw("failed to decode channel.chat.message event", "error", err)
+		return
+	}
+
+	msg, err := irc.ParseMessage(chatMessageToIRC(event, notification.Metadata.MessageTimestamp))
+	if err != nil {
+		zap.S().Errorw("failed to parse converted chat message", "error", err)
+		return
+	}
+	s.onMessage(msg, nil)
+}
+
+// chatMessageToIRC converts a channel.chat.message event to a PRIVMSG line with the tags we would get from IRC
+func chatMessageToIRC(event *eventsub.ChatMessageEvent, sent time.Time) string {
+	var badges []string
+	for _, badge := range event.Badges {
+		badges = append(badges, badge.SetID+"/"+badge.ID)
+	}
+
+	tags := []string{
+		"badges=" + strings.Join(badges, ","),
+		"color=" + event.Color,
+		"display-name=" + escapeTagValue(event.ChatterUserName),
+		"emotes=" + emotePositions(event.Message.Fragments),
+		"id=" + event.MessageID,
+		"room-id=" + event.BroadcasterUserID,
+		"tmi-sent-ts=" + strconv.FormatInt(sent.UnixMilli(), 10),
+		"user-id=" + event.ChatterUserID,
+	}
+	if event.Reply != nil {
+		tags = append(tags,
+			"reply-parent-msg-id="+event.Reply.ParentMessageID,
+			"reply-parent-msg-body="+escapeTagValue(event.Reply.ParentMessageBody),
+			"reply-parent-user-id="+event.Reply.ParentUserID,
+			"reply-parent-user-login="+event.Reply.ParentUserLogin,
+			"reply-parent-display-name="+escapeTagValue(event.Reply.ParentUserName),
+			"reply-thread-parent-msg-id="+event.Reply.ThreadMessageID,
+			"reply-thread-parent-user-login="+event.Reply.ThreadUserLogin,
+		)
+	}
+	if event.Cheer != nil {
+		tags = append(tags, "bits="+strconv.Itoa(event.Cheer.Bits))
+	}
+
+	return fmt.Sprintf(
+		"@%v :%v!%v@%v.tmi.twitch.tv PRIVMSG #%v :%v",
+		strings.Join(tags, ";"),
+		event.ChatterUserLogin,
+		event.ChatterUserLogin,
+		event.ChatterUserLogin,
+		event.BroadcasterUserLogin,
+		event.Message.Text,
+	)
+}
+
+// emotePositions returns the value of the IRC emotes tag, e.g. "25:0-4,12-16/1902:6-10".
+// Positions are counted in characters, not bytes.
+func emotePositions(fragments []eventsub.MessageFragment) string {
+	var order []string
+	positions := make(map[string][]string)
+
+	offset := 0
+	for _, fragment := range fragments {
+		length := len([]rune(fragment.Text))
+		if fragment.Type == "emote" && fragment.Emote != nil && length > 0 {
+			id := fragment.Emote.ID
+			if _, ok := positions[id]; !ok {
+				order = append(order, id)
+			}
+			positions[id] = append(positions[id], fmt.Sprintf("%v-%v", offset, offset+length-1))
+		}
+		offset += length
+	}
+
+	result := make([]string, 0, len(order))
+	for _, id := range order {
+		result = append(result, id+":"+strings.Join(positions[id], ","))
+	}
+	return strings.Join(result, "/")
+}
+
+var tagValueEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	";", "\\:",
+	" ", "\\s",
+	"\r", "\\r",
+	"\n", "\\n",
+)
+
+// escapeTagValue escapes a value for use in IRC message tags
+func escapeTagValue(value string) string {
+	return tagValueEscaper.Replace(value)
+}
@@ -0,0 +1,51 @@
+package irc_reader
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/seventv/7tv-bot/pkg/eventsub"
+	"github.com/seventv/7tv-bot/pkg/irc"
+)
+
+func Test_chatMessageToIRC(t *testing.T) {
+	type args struct {
+		event string
+		sent  time.Time
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "emotes",
+			args: args{
+				event: `{"broadcaster_user_id":"26301881","broadcaster_user_login":"sodapoppin","broadcaster_user_name":"sodapoppin","chatter_user_id":"237719657","chatter_user_login":"fossabot","chatter_user_name":"Fossabot","message_id":"23ebb86b-f9fa-47b8-893c-708587661afc","message":{"text":"Kappa ° Kappa PogChamp","fragments":[{"type":"emote","text":"Kappa","emote":{"id":"25"}},{"type":"text","text":" ° "},{"type":"emote","text":"Kappa","emote":{"id":"25"}},{"type":"text","text":" "},{"type":"emote","text":"PogChamp","emote":{"id":"305954156"}}]},"color":"#1976D2","badges":[{"set_id":"moderator","id":"1","info":""},{"set_id":"partner","id":"1","info":""}],"message_type":"text"}`,
+				sent:  time.UnixMilli(1690815698066),
+			},
+			want: "@badges=moderator/1,partner/1;color=#1976D2;display-name=Fossabot;emotes=25:0-4,8-12/305954156:14-21;id=23ebb86b-f9fa-47b8-893c-708587661afc;room-id=26301881;tmi-sent-ts=1690815698066;user-id=237719657 :fossabot!fossabot@fossabot.tmi.twitch.tv PRIVMSG #sodapoppin :Kappa ° Kappa PogChamp",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &eventsub.ChatMessageEvent{}
+			err := json.Unmarshal([]byte(tt.args.event), event)
+			if err != nil {
+				t.Fatalf("failed to decode event: %v", err)
+			}
+			got := chatMessageToIRC(event, tt.args.sent)
+			if got != tt.want {
+				t.Errorf("chatMessageToIRC() = %v, want %v", got, tt.want)
+			}
+			// the converted message must be handled the same way as messages from IRC
+			if _, channel := parseCommand(got); channel != "sodapoppin" {
+				t.Errorf("parseCommand() channel = %v, want sodapoppin", channel)
+			}
+			if msg, _ := irc.ParseMessage(got); msg.MessageID() != event.MessageID {
+				t.Errorf("MessageID() = %v, want %v", msg.MessageID(), event.MessageID)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package irc_reader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/manager"
+)
+
+// handoffBatchSize is the maximum amount of channels sent per handoff request, batches are spread across the other readers
+const handoffBatchSize = 50
+
+// holds records the adopted channels & until when they're kept, so reconciliations don't part them before their new owner joined
+type holds struct {
+	mx    sync.Mutex
+	until map[string]time.Time
+}
+
+func (h *holds) add(name string, until time.Time) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if h.until == nil {
+		h.until = make(map[string]time.Time)
+	}
+	h.until[name] = until
+}
+
+// held returns true if the channel was adopted & its hold hasn't expired yet, expired holds are removed
+func (h *holds) held(name string, now time.Time) bool {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	until, ok := h.until[name]
+	if !ok {
+		return false
+	}
+	if now.Before(until) {
+		return true
+	}
+	delete(h.until, name)
+	return false
+}
+
+// handoffRequest asks another reader to join the channels of a reader that's shutting down
+type handoffRequest struct {
+	From     string           `json:"from"`
+	Channels []handoffChannel `json:"channels"`
+}
+
+type handoffChannel struct {
+	Username string `json:"username"`
+	Weight   int    `json:"weight"`
+}
+
+// handoffReply confirms which channels of the request were joined
+type handoffReply struct {
+	Joined []string `json:"joined"`
+}
+
+// handleHandoffs adopts channels from readers that are shutting down. All readers share a queue group,
+// so every request is handled by a single reader. Adopted channels that aren't assigned to us are parted again
+// by a reconciliation after the hold duration, once their new owner had time to join them.
+func (c *Controller) handleHandoffs(nc *nats.Conn) error {
+	var err error
+	c.handoffSub, err = nc.QueueSubscribe(c.cfg.Handoff.Subject, "irc-reader", func(msg *nats.Msg) {
+		request := handoffRequest{}
+		err := json.Unmarshal(msg.Data, &request)
+		if err != nil {
+			zap.S().Errorw("failed to unmarshal handoff request", "error", err)
+			return
+		}
+		zap.S().Infow("adopting channels", "from", request.From, "channels", len(request.Channels))
+
+		reply := handoffReply{}
+		until := time.Now().Add(c.cfg.Handoff.Hold)
+		var wg sync.WaitGroup
+		var mx sync.Mutex
+		for _, channel := range request.Channels {
+			wg.Add(1)
+			go func(channel handoffChannel) {
+				defer wg.Done()
+				err := c.twitch.Join(channel.Username, channel.Weight)
+				if err != nil && !errors.Is(err, manager.ErrChanAlreadyJoined) {
+					zap.S().Errorw("failed to adopt channel", "error", err, "channel", channel.Username)
+					return
+				}
+				c.adopted.add(channel.Username, until)
+				mx.Lock()
+				reply.Joined = append(reply.Joined, channel.Username)
+				mx.Unlock()
+			}(channel)
+		}
+		wg.Wait()
+
+		data, err := json.Marshal(reply)
+		if err != nil {
+			zap.S().Errorw("failed to marshal handoff reply", "error", err)
+			return
+		}
+		err = msg.Respond(data)
+		if err != nil {
+			zap.S().Errorw("failed to reply to handoff request", "error", err)
+		}
+
+		time.AfterFunc(c.cfg.Handoff.Hold, func() {
+			err := c.reconcile(context.Background())
+			if err != nil {
+				zap.S().Errorw("failed to reconcile adopted channels", "error", err)
+			}
+		})
+	})
+	return err
+}
+
+// drain hands our channels off to the other readers, and waits until they confirmed joining them or the timeout passed.
+// Channels read through EventSub aren't handed off, they're picked up by their next owner.
+func (c *Controller) drain() {
+	// stop adopting channels ourselves
+	if c.handoffSub != nil {
+		_ = c.handoffSub.Unsubscribe()
+	}
+
+	channels := c.twitch.Channels()
+	if len(channels) == 0 {
+		return
+	}
+	from, _ := os.Hostname()
+	zap.S().Infow("handing off channels", "channels", len(channels))
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Handoff.Timeout)
+	defer cancel()
+
+	batches, skipped := handoffBatches(channels, c.cfg.RateLimit.Join, c.cfg.RateLimit.Reset, c.cfg.Handoff.Timeout)
+	if skipped > 0 {
+		zap.S().Warnw("can't hand off all channels within the join rate limit before the timeout, they're joined by their new owner",
+			"skipped", skipped, "channels", len(channels))
+	}
+
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	joined := 0
+	for _, batch := range batches {
+		request := handoffRequest{From: from}
+		for _, channel := range batch {
+			request.Channels = append(request.Channels, handoffChannel{Username: channel.Name, Weight: channel.Weight})
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply, err := c.requestHandoff(ctx, request)
+			if err != nil {
+				zap.S().Errorw("failed to hand off channels", "error", err, "channels", len(request.Channels))
+				return
+			}
+			mx.Lock()
+			joined += len(reply.Joined)
+			mx.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	zap.S().Infow("handed off channels", "joined", joined, "channels", len(channels))
+}
+
+// handoffBatches splits the channels in batches the other readers can join within the timeout.
+// Joins go through the shared join rate limiter, so at most join channels are joined per reset, and a batch is never bigger than that.
+// The heaviest channels are handed off first, returns the amount of channels that don't fit in the join budget of the timeout.
+func handoffBatches(channels []manager.IRCChannel, join int64, reset, timeout time.Duration) ([][]manager.IRCChannel, int) {
+	size := handoffBatchSize
+	budget := len(channels)
+	if join > 0 && reset > 0 {
+		if int(join) < size {
+			size = int(join)
+		}
+		// every reset window allows join joins, only windows that end before the timeout count
+		budget = int(timeout/reset) * int(join)
+		if budget < size {
+			budget = size
+		}
+	}
+
+	sorted := make([]manager.IRCChannel, len(channels))
+	copy(sorted, channels)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Weight > sorted[j].Weight
+	})
+
+	skipped := 0
+	if len(sorted) > budget {
+		skipped = len(sorted) - budget
+		sorted = sorted[:budget]
+	}
+
+	var batches [][]manager.IRCChannel
+	for len(sorted) > size {
+		batches = append(batches, sorted[:size])
+		sorted = sorted[size:]
+	}
+	if len(sorted) > 0 {
+		batches = append(batches, sorted)
+	}
+	return batches, skipped
+}
+
+func (c *Controller) requestHandoff(ctx context.Context, request handoffRequest) (*handoffReply, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := c.nc.RequestWithContext(ctx, c.cfg.Handoff.Subject, data)
+	if err != nil {
+		return nil, err
+	}
+	reply := &handoffReply{}
+	err = json.Unmarshal(msg.Data, reply)
+	return reply, err
+}
@@ -0,0 +1,88 @@
+package irc_reader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/seventv/7tv-bot/pkg/manager"
+)
+
+func Test_handoffBatches(t *testing.T) {
+	channels := make([]manager.IRCChannel, 100)
+	for i := range channels {
+		channels[i] = manager.IRCChannel{Name: "channel", Weight: i}
+	}
+
+	tests := []struct {
+		name        string
+		join        int64
+		reset       time.Duration
+		timeout     time.Duration
+		wantBatches []int
+		wantSkipped int
+	}{
+		{
+			name:        "no rate limit",
+			timeout:     20 * time.Second,
+			wantBatches: []int{50, 50},
+		},
+		{
+			name:        "default rate limit",
+			join:        20,
+			reset:       10 * time.Second,
+			timeout:     20 * time.Second,
+			wantBatches: []int{20, 20},
+			wantSkipped: 60,
+		},
+		{
+			name:        "timeout shorter than a window",
+			join:        20,
+			reset:       10 * time.Second,
+			timeout:     5 * time.Second,
+			wantBatches: []int{20},
+			wantSkipped: 80,
+		},
+		{
+			name:        "everything fits",
+			join:        2000,
+			reset:       10 * time.Second,
+			timeout:     20 * time.Second,
+			wantBatches: []int{50, 50},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches, skipped := handoffBatches(channels, tt.join, tt.reset, tt.timeout)
+			if skipped != tt.wantSkipped {
+				t.Errorf("handoffBatches() skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+			if len(batches) != len(tt.wantBatches) {
+				t.Fatalf("handoffBatches() returned %v batches, want %v", len(batches), len(tt.wantBatches))
+			}
+			for i, batch := range batches {
+				if len(batch) != tt.wantBatches[i] {
+					t.Errorf("handoffBatches() batch %v has length %v, want %v", i, len(batch), tt.wantBatches[i])
+				}
+			}
+			// heaviest channels first
+			if batches[0][0].Weight != 99 {
+				t.Errorf("handoffBatches() first channel has weight %v, want 99", batches[0][0].Weight)
+			}
+		})
+	}
+}
+
+func Test_holds_held(t *testing.T) {
+	now := time.Now()
+	h := holds{}
+	h.add("forsen", now.Add(time.Minute))
+	if !h.held("forsen", now) {
+		t.Error("held() = false before the hold passed, want true")
+	}
+	if h.held("sodapoppin", now) {
+		t.Error("held() = true for a channel that wasn't adopted, want false")
+	}
+	if h.held("forsen", now.Add(2*time.Minute)) {
+		t.Error("held() = true after the hold passed, want false")
+	}
+}
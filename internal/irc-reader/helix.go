@@ -0,0 +1,53 @@
+package irc_reader
+
+import (
+	"context"
+	"strings"
+
+	"github.com/seventv/7tv-bot/pkg/helix"
+)
+
+// initHelix sets up the Helix clients needed for the enabled features.
+// EventSub WebSocket subscriptions require a user access token, polling stream status uses the app access token.
+func (c *Controller) initHelix(ctx context.Context, oauth string) error {
+	if c.cfg.Eventsub.Enabled || (c.cfg.Live.Enabled && c.cfg.Live.Source == liveSourceEventSub) {
+		c.userHelix = c.newHelix(strings.TrimPrefix(oauth, "oauth:"))
+	}
+
+	if c.cfg.Live.Enabled && c.cfg.Live.Source == liveSourceHelix {
+		token := c.cfg.Twitch.Apptoken
+		if token == "" {
+			var err error
+			token, err = c.getAppTokenFromKubeSecret(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		c.helix = c.newHelix(token)
+	}
+	return nil
+}
+
+func (c *Controller) newHelix(token string) *helix.Client {
+	client := helix.New(c.cfg.Twitch.Clientid, token)
+	if c.cfg.Helix.URL != "" {
+		client.WithBaseURL(c.cfg.Helix.URL)
+	}
+	return client
+}
+
+// updateHelixTokens updates the tokens of the Helix clients after the kubernetes secret changed
+func (c *Controller) updateHelixTokens(ctx context.Context, oauth string) error {
+	if c.userHelix != nil {
+		c.userHelix.UpdateToken(strings.TrimPrefix(oauth, "oauth:"))
+	}
+	if c.helix == nil || c.cfg.Twitch.Apptoken != "" {
+		return nil
+	}
+	token, err := c.getAppTokenFromKubeSecret(ctx)
+	if err != nil {
+		return err
+	}
+	c.helix.UpdateToken(token)
+	return nil
+}
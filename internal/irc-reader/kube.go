@@ -13,6 +13,7 @@ import (
 
 var (
 	ErrNoOAuthToken = errors.New("no OAuth token found in kubernetes secret data")
+	ErrNoAppToken   = errors.New("no app access token found in kubernetes secret data")
 	ErrNoSecret     = errors.New("no secret found")
 )
 
@@ -53,7 +54,8 @@ func (c *Controller) updateOauthFromKubeSecret() error {
 	}
 	c.twitch.UpdateOauth(oauth)
 	zap.S().Info("updated OAuth token from kubernetes secret")
-	return nil
+
+	return c.updateHelixTokens(context.Background(), oauth)
 }
 
 func (c *Controller) getOauthFromKubeSecret(ctx context.Context) (string, error) {
@@ -74,3 +76,22 @@ func (c *Controller) getOauthFromKubeSecret(ctx context.Context) (string, error)
 	}
 	return fmt.Sprintf("oauth:%v", string(data)), nil
 }
+
+func (c *Controller) getAppTokenFromKubeSecret(ctx context.Context) (string, error) {
+	secret, err := c.kube.CoreV1().Secrets(c.cfg.Kube.Namespace).Get(
+		ctx,
+		c.cfg.Kube.Oauthsecret,
+		metav1.GetOptions{},
+	)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", ErrNoSecret
+	}
+	data, ok := secret.Data["app-access-token"]
+	if !ok || len(data) == 0 {
+		return "", ErrNoAppToken
+	}
+	return string(data), nil
+}
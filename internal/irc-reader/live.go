@@ -0,0 +1,209 @@
+package irc_reader
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/eventsub"
+	"github.com/seventv/7tv-bot/pkg/streamstatus"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+var ErrUnknownLiveSource = errors.New("unknown stream status source")
+
+const (
+	liveSourceHelix    = "helix"
+	liveSourceEventSub = "eventsub"
+)
+
+// liveTracker makes sure channels below the weight threshold are only joined while they're live
+type liveTracker struct {
+	source    streamstatus.Source
+	threshold int
+	grace     time.Duration
+
+	join func(channel types.Channel)
+	part func(channel types.Channel)
+
+	mx sync.Mutex
+	// channels maps the tracked channel IDs to their state
+	channels map[string]*liveChannel
+}
+
+type liveChannel struct {
+	channel types.Channel
+	joined  bool
+	// offline is set while the channel is in its grace period after going offline
+	offline *time.Timer
+}
+
+func newLiveTracker(source streamstatus.Source, threshold int, grace time.Duration) *liveTracker {
+	return &liveTracker{
+		source:    source,
+		threshold: threshold,
+		grace:     grace,
+		channels:  make(map[string]*liveChannel),
+	}
+}
+
+// start blocks, joining & parting channels as they go live or offline
+func (t *liveTracker) start(ctx context.Context) {
+	err := t.source.Start(ctx, t.onChange)
+	if err != nil && ctx.Err() == nil {
+		zap.S().Errorw("stream status source stopped", "error", err)
+	}
+}
+
+// tracks returns true if the channel should only be joined while it's live
+func (t *liveTracker) tracks(channel types.Channel) bool {
+	return channel.Weight < t.threshold
+}
+
+// add starts tracking the stream status of the channel, the channel gets joined once it's live.
+// Returns false if the stream status can't be tracked, the channel should then always be joined.
+func (t *liveTracker) add(channel types.Channel) bool {
+	id := strconv.FormatInt(channel.ID, 10)
+
+	t.mx.Lock()
+	lc, ok := t.channels[id]
+	if ok {
+		// channel is already tracked, update its data in case it got renamed
+		lc.channel = channel
+		joined := lc.joined
+		t.mx.Unlock()
+		if joined {
+			t.join(channel)
+		}
+		return true
+	}
+	t.channels[id] = &liveChannel{channel: channel}
+	t.mx.Unlock()
+
+	err := t.source.Add(context.TODO(), id)
+	if err != nil {
+		zap.S().Errorw("failed to track stream status, joining channel regardless", "error", err, "channel", channel.Username)
+		t.mx.Lock()
+		delete(t.channels, id)
+		t.mx.Unlock()
+		return false
+	}
+	return true
+}
+
+// remove stops tracking the stream status of the channel, without parting it
+func (t *liveTracker) remove(channel types.Channel) {
+	id := strconv.FormatInt(channel.ID, 10)
+
+	t.mx.Lock()
+	lc, ok := t.channels[id]
+	if !ok {
+		t.mx.Unlock()
+		return
+	}
+	if lc.offline != nil {
+		lc.offline.Stop()
+	}
+	delete(t.channels, id)
+	t.mx.Unlock()
+
+	err := t.source.Remove(context.TODO(), id)
+	if err != nil {
+		zap.S().Errorw("failed to stop tracking stream status", "error", err, "channel", channel.Username)
+	}
+}
+
+// state returns whether the channel is tracked, and whether it's currently meant to be joined
+func (t *liveTracker) state(channel types.Channel) (tracked, joined bool) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	lc, ok := t.channels[strconv.FormatInt(channel.ID, 10)]
+	if !ok {
+		return false, false
+	}
+	return true, lc.joined
+}
+
+func (t *liveTracker) onChange(change streamstatus.Change) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	lc, ok := t.channels[change.ChannelID]
+	if !ok {
+		return
+	}
+
+	if change.Live {
+		// channel came back online during the grace period
+		if lc.offline != nil {
+			lc.offline.Stop()
+			lc.offline = nil
+		}
+		if lc.joined {
+			return
+		}
+		lc.joined = true
+		zap.S().Infof("channel went live: %v", lc.channel.Username)
+		go t.join(lc.channel)
+		return
+	}
+
+	if !lc.joined || lc.offline != nil {
+		return
+	}
+	zap.S().Infof("channel went offline: %v, parting in %v", lc.channel.Username, t.grace)
+	var timer *time.Timer
+	timer = time.AfterFunc(t.grace, func() {
+		t.mx.Lock()
+		defer t.mx.Unlock()
+		// the grace period was cancelled, or the channel is no longer tracked
+		if lc.offline != timer || t.channels[change.ChannelID] != lc {
+			return
+		}
+		lc.offline = nil
+		lc.joined = false
+		go t.part(lc.channel)
+	})
+	lc.offline = timer
+}
+
+// initLive sets up the live tracker with the configured stream status source
+func (c *Controller) initLive(ctx context.Context) error {
+	var source streamstatus.Source
+	switch c.cfg.Live.Source {
+	case liveSourceEventSub:
+		client := eventsub.New()
+		if c.cfg.Eventsub.URL != "" {
+			client.WithURL(c.cfg.Eventsub.URL)
+		}
+		source = streamstatus.NewEventSub(client, c.userHelix)
+	case liveSourceHelix:
+		interval := c.cfg.Live.Interval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		source = streamstatus.NewPoller(c.helix, interval)
+	default:
+		return ErrUnknownLiveSource
+	}
+
+	c.live = newLiveTracker(source, c.cfg.Live.Threshold, c.cfg.Live.Grace)
+	c.live.join = c.join
+	c.live.part = func(channel types.Channel) {
+		zap.S().Infof("parting offline channel: %v", channel.Username)
+		err := c.twitch.Part(channel.Username)
+		if err != nil {
+			zap.L().Error(
+				"failed to part offline channel",
+				zap.String("error", err.Error()),
+				zap.String("channel", channel.Username),
+			)
+		}
+	}
+	go c.live.start(ctx)
+	return nil
+}
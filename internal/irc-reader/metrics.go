@@ -0,0 +1,83 @@
+package irc_reader
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/publisher"
+)
+
+var (
+	reconcileRuns = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "irc_reader_reconcile_runs_total",
+		Help: "Amount of reconciliations between the channels in the database & the joined channels",
+	})
+	reconcileDrift = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "irc_reader_reconcile_drift_total",
+		Help: "Amount of channels found out of sync during reconciliation, by the action taken to fix them",
+	}, []string{"action"})
+	reconcileErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "irc_reader_reconcile_errors_total",
+		Help: "Amount of reconciliations that failed",
+	})
+	desiredChannels = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "irc_reader_desired_channels",
+		Help: "Amount of channels assigned to this reader, as of the last reconciliation",
+	})
+	joinedChannels = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "irc_reader_joined_channels",
+		Help: "Amount of channels joined on IRC, as of the last reconciliation",
+	})
+)
+
+// registerPublisherMetrics exposes the state of the publish queue, to see backpressure while NATS is slow or unavailable
+func registerPublisherMetrics(p *publisher.Publisher) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "irc_reader_publish_queue_length",
+		Help: "Amount of messages waiting in memory to be published",
+	}, func() float64 { return float64(p.Stats().Queued) })
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "irc_reader_publish_spill_bytes",
+		Help: "Size of the messages spilled to disk, waiting to be replayed",
+	}, func() float64 { return float64(p.Stats().SpillBytes) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "irc_reader_published_total",
+		Help: "Amount of messages published to NATS from the queue",
+	}, func() float64 { return float64(p.Stats().Published) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "irc_reader_publish_spilled_total",
+		Help: "Amount of messages spilled to disk, because the queue was full or NATS was unavailable",
+	}, func() float64 { return float64(p.Stats().Spilled) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "irc_reader_publish_replayed_total",
+		Help: "Amount of spilled messages published to NATS",
+	}, func() float64 { return float64(p.Stats().Replayed) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "irc_reader_publish_dropped_total",
+		Help: "Amount of messages lost, because they could neither be queued nor spilled",
+	}, func() float64 { return float64(p.Stats().Dropped) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "irc_reader_publish_failures_total",
+		Help: "Amount of failed attempts to publish a message to NATS",
+	}, func() float64 { return float64(p.Stats().Failed) })
+}
+
+// serveMetrics exposes the prometheus metrics on /metrics
+func (c *Controller) serveMetrics() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := http.Server{
+		Addr:    "0.0.0.0:" + c.cfg.Prometheus.Port,
+		Handler: mux,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			zap.S().Errorw("failed to serve metrics", "error", err)
+		}
+	}()
+}
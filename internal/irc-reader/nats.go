@@ -1,8 +1,11 @@
 package irc_reader
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -10,6 +13,9 @@ import (
 
 	"github.com/seventv/7tv-bot/internal/database"
 	"github.com/seventv/7tv-bot/pkg/bitwise"
+	"github.com/seventv/7tv-bot/pkg/message"
+	"github.com/seventv/7tv-bot/pkg/publisher"
+	"github.com/seventv/7tv-bot/pkg/stream"
 	"github.com/seventv/7tv-bot/pkg/types"
 )
 
@@ -18,53 +24,186 @@ import (
 func (c *Controller) ensureStream(js nats.JetStreamContext) error {
 	cfg := &nats.StreamConfig{
 		Name:      c.cfg.Nats.Stream,
-		Subjects:  []string{c.cfg.Nats.Topic.Raw + ".>"},
+		Subjects:  c.streamSubjects(),
 		MaxAge:    1 * time.Hour,
 		Retention: nats.InterestPolicy,
-		Discard:   nats.DiscardNew,
-		// TODO: 0 seconds sets this to default value (2 min), find optimal value for our case
+		// drop the oldest messages when the stream is full, so publishing doesn't fail when the aggregator falls behind
+		Discard: nats.DiscardOld,
+		// redundant readers publish the same messages, the window must cover the delay between them
 		Duplicates: 1 * time.Minute,
 	}
-
-	_, err := js.StreamInfo(cfg.Name)
-	if errors.Is(err, nats.ErrStreamNotFound) {
-		_, err = js.AddStream(cfg)
+	err := c.cfg.Nats.Limits.Apply(cfg)
+	if err != nil {
 		return err
-		//created = true
 	}
-	_, err = js.UpdateStream(cfg)
 
-	return err
+	// we publish to this stream, so we own it
+	return stream.Ensure(js, cfg, true)
+}
+
+// streamSubjects returns the subjects of all messages we publish to the stream
+func (c *Controller) streamSubjects() []string {
+	// covers all published commands, <raw>.<command>.<channel>
+	subjects := []string{c.cfg.Nats.Topic.Raw + ".>"}
+	if c.cfg.Nats.Topic.Normalized != "" {
+		subjects = append(subjects, c.cfg.Nats.Topic.Normalized+".>")
+	}
+	return subjects
+}
+
+// publishNormalized publishes the message in the pkg/message schema, if a subject for it is configured.
+// id is the dedup ID of the raw message, so redundant readers deduplicate both the same way.
+func (c *Controller) publishNormalized(raw, id string, receivedAt time.Time) {
+	if c.cfg.Nats.Topic.Normalized == "" {
+		return
+	}
+
+	msg, err := message.FromIRC(raw, receivedAt)
+	if err != nil {
+		zap.L().Error(
+			"failed to normalize message",
+			zap.String("error", err.Error()),
+		)
+		return
+	}
+
+	format := c.cfg.Nats.Format
+	if format == "" {
+		format = message.FormatJSON
+	}
+	data, err := message.Marshal(msg, format)
+	if err != nil {
+		zap.L().Error(
+			"failed to encode normalized message",
+			zap.String("error", err.Error()),
+			zap.String("format", format),
+		)
+		return
+	}
+
+	header := nats.Header{}
+	// the raw message shares the stream, so the ID needs a suffix to not be filtered out as a duplicate
+	if id != "" {
+		header.Add("Nats-Msg-Id", id+".normalized")
+	}
+	header.Add(message.HeaderFormat, format)
+	header.Add(message.HeaderVersion, strconv.Itoa(msg.Version))
+
+	c.publisher.Publish(&nats.Msg{
+		Subject: c.cfg.Nats.Topic.Normalized + ".privmsg." + msg.Channel.Login,
+		Header:  header,
+		Data:    data,
+	})
+}
+
+// initPublisher starts publishing messages in the background, with the metrics of the queue
+func (c *Controller) initPublisher() error {
+	c.publisher = publisher.New(c.jetStream).WithWorkers(c.cfg.Publish.Workers)
+	if c.cfg.Publish.Queue > 0 {
+		c.publisher.WithQueue(c.cfg.Publish.Queue)
+	}
+	if c.cfg.Publish.Spill.Dir != "" {
+		c.publisher.WithSpill(c.cfg.Publish.Spill.Dir, c.cfg.Publish.Spill.MaxBytes)
+	}
+	registerPublisherMetrics(c.publisher)
+
+	return c.publisher.Start()
+}
+
+// ensureChangesStream makes sure the stream for channel changes exists, it's owned by the API
+func (c *Controller) ensureChangesStream(js nats.JetStreamContext) error {
+	return stream.Ensure(js, &nats.StreamConfig{
+		Name:       c.cfg.Nats.Changes.Stream,
+		Subjects:   []string{c.cfg.Nats.Topic.Api + ".>"},
+		MaxAge:     7 * 24 * time.Hour,
+		Retention:  nats.LimitsPolicy,
+		Storage:    nats.FileStorage,
+		Duplicates: 1 * time.Minute,
+	}, false)
 }
 
-// Watch NATS subject for database changes sent from the API,
-// we don't use jetstream here because we don't need this buffered
-func (c *Controller) watchChanges(nc *nats.Conn) {
-	nc.Subscribe(c.cfg.Nats.Topic.Api, func(msg *nats.Msg) {
-		channel := types.Channel{}
-		err := json.Unmarshal(msg.Data, &channel)
+// subscribeChanges creates our durable consumer for channel changes sent from the API.
+// Every reader has its own consumer, so each of them gets all changes, and resumes after the last acked change on restart.
+// New consumers only get changes from now on, the current channels are read from the database.
+func (c *Controller) subscribeChanges() (*nats.Subscription, error) {
+	err := c.ensureChangesStream(c.jetStream)
+	if err != nil {
+		return nil, err
+	}
+
+	consumer := c.cfg.Nats.Changes.Consumer
+	if consumer == "" {
+		consumer, err = os.Hostname()
 		if err != nil {
-			zap.S().Error("failed to unmarshal insert", err)
-			return
+			return nil, err
 		}
+	}
+
+	err = stream.EnsureConsumer(c.jetStream, c.cfg.Nats.Changes.Stream, &nats.ConsumerConfig{
+		Durable:       consumer,
+		FilterSubject: c.cfg.Nats.Topic.Api + ".>",
+		DeliverPolicy: nats.DeliverNewPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		// readers that were scaled down don't come back, so their consumers can be cleaned up
+		InactiveThreshold: 24 * time.Hour,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		op := msg.Header.Get("OP")
+	return c.jetStream.PullSubscribe(c.cfg.Nats.Topic.Api+".>", consumer, nats.Bind(c.cfg.Nats.Changes.Stream, consumer))
+}
 
-		switch op {
-		case database.Insert:
-			// if the channel is not flagged to join, we skip past it
-			if !bitwise.Has(channel.Flags, bitwise.JOIN_IRC) {
-				return
+// watchChanges blocks, handling channel changes sent from the API in the order they were published, until ctx is cancelled.
+// Changes are only acked after they're handled, so they're redelivered if we crash in the meantime.
+func (c *Controller) watchChanges(ctx context.Context, sub *nats.Subscription) {
+	for {
+		msgs, err := sub.Fetch(10, nats.Context(ctx))
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, nats.ErrTimeout) {
+				zap.S().Errorw("failed to fetch channel changes", "error", err)
+				time.Sleep(time.Second)
 			}
-			c.joinChannel(channel)
-		case database.Update:
-			// TODO: implement
+			continue
+		}
+
+		for _, msg := range msgs {
+			event := types.ChannelEvent{}
+			err = json.Unmarshal(msg.Data, &event)
+			if err != nil {
+				zap.S().Errorw("failed to unmarshal channel change", "error", err)
+				// redelivering won't fix a malformed message
+				_ = msg.Term()
+				continue
+			}
+			if event.Version > types.ChannelEventVersion {
+				zap.S().Warnw("channel change has a newer version than supported", "version", event.Version)
+			}
+
+			c.handleChange(event.Op, event.Channel)
+			_ = msg.Ack()
+		}
+	}
+}
+
+// handleChange joins, updates or parts a channel after it was changed in the database
+func (c *Controller) handleChange(op string, channel types.Channel) {
+	switch op {
+	case database.Insert:
+		// if the channel is not flagged to join, we skip past it
+		if !bitwise.Has(channel.Flags, bitwise.JOIN_IRC) && !bitwise.Has(channel.Flags, bitwise.JOIN_EVENTSUB) {
 			return
-		case database.Delete:
-			println("parting: " + channel.Username)
-			c.twitch.Part(channel.Username)
-		default:
-			zap.S().Error("unknown database operation", op)
 		}
-	})
+		c.joinChannel(channel)
+	case database.Update:
+		c.updateChannel(channel)
+	case database.Delete:
+		zap.S().Infof("parting: %v", channel.Username)
+		c.partChannel(channel)
+	default:
+		zap.S().Error("unknown database operation", op)
+	}
 }
@@ -0,0 +1,149 @@
+package irc_reader
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/internal/database"
+	"github.com/seventv/7tv-bot/pkg/bitwise"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+// reconcileLoop periodically fixes drift between the channels in the database & the channels we're reading.
+// Changes are delivered by a durable consumer, so this is a safety net for changes that were termed as malformed,
+// or expired from the stream or with our consumer after the InactiveThreshold.
+func (c *Controller) reconcileLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := c.reconcile(ctx)
+			if err != nil {
+				reconcileErrors.Inc()
+				zap.S().Errorw("failed to reconcile channels", "error", err)
+			}
+		}
+	}
+}
+
+// reconcile joins the channels assigned to us that aren't being read, and parts the joined channels that aren't assigned to us
+func (c *Controller) reconcile(ctx context.Context) error {
+	reconcileRuns.Inc()
+
+	// channels assigned to us that should be on IRC, mapped by username
+	desired := make(map[string]types.Channel)
+	var assigned []types.Channel
+
+	err := database.GetChannels(ctx, func(channels []types.Channel) {
+		for _, channel := range channels {
+			if !c.shouldJoin(channel) {
+				continue
+			}
+			assigned = append(assigned, channel)
+			if c.onIRC(channel) {
+				desired[strings.ToLower(channel.Username)] = channel
+			}
+		}
+	}, 100)
+	if err != nil {
+		return err
+	}
+
+	// snapshot the joined channels after reading the desired set, so channels joined while reading aren't seen as missing
+	joined := make(map[string]bool)
+	for _, channel := range c.twitch.Channels() {
+		joined[channel.Name] = true
+	}
+	var missing []types.Channel
+	for _, channel := range assigned {
+		if c.missing(channel, joined) {
+			missing = append(missing, channel)
+		}
+	}
+
+	for name := range joined {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		// adopted channels are kept until their new owner had time to join them
+		if c.adopted.held(name, time.Now()) {
+			continue
+		}
+		// the channel could've been added & joined after we read the desired set
+		if c.stillDesired(ctx, name) {
+			continue
+		}
+		zap.S().Infow("reconcile: parting channel that isn't assigned to us", "channel", name)
+		reconcileDrift.WithLabelValues("part").Inc()
+		err = c.twitch.Part(name)
+		if err != nil {
+			zap.S().Errorw("failed to part channel", "error", err, "channel", name)
+		}
+	}
+
+	for _, channel := range missing {
+		zap.S().Infow("reconcile: joining channel that isn't being read", "channel", channel.Username)
+		reconcileDrift.WithLabelValues("join").Inc()
+		c.joinChannel(channel)
+	}
+
+	desiredChannels.Set(float64(len(desired)))
+	joinedChannels.Set(float64(len(joined)))
+	return nil
+}
+
+// stillDesired re-reads the channel from the database, and returns true if it should be joined on IRC after all
+func (c *Controller) stillDesired(ctx context.Context, name string) bool {
+	channel, err := database.GetChannel(ctx, bson.D{{"username", primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}}})
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			zap.S().Errorw("failed to re-check channel before parting", "error", err, "channel", name)
+			// don't part on errors, the next reconcile will try again
+			return true
+		}
+		return false
+	}
+	return c.shouldJoin(*channel) && c.onIRC(*channel)
+}
+
+// onIRC returns true if the channel is allowed to be joined on IRC,
+// offline channels tracked by the live tracker are included, they're parted by the tracker after the grace period
+func (c *Controller) onIRC(channel types.Channel) bool {
+	if !bitwise.Has(channel.Flags, bitwise.JOIN_IRC) {
+		return false
+	}
+	return c.chat == nil || !c.chat.has(channel)
+}
+
+// missing returns true if the channel should be read, but isn't joined on IRC nor subscribed to through EventSub
+func (c *Controller) missing(channel types.Channel, joined map[string]bool) bool {
+	if c.chat != nil && bitwise.Has(channel.Flags, bitwise.JOIN_EVENTSUB) {
+		if c.chat.has(channel) {
+			return false
+		}
+		// channels without a subscription fall back to IRC if they're flagged for it
+		if !bitwise.Has(channel.Flags, bitwise.JOIN_IRC) {
+			return true
+		}
+	}
+	if !bitwise.Has(channel.Flags, bitwise.JOIN_IRC) || joined[strings.ToLower(channel.Username)] {
+		return false
+	}
+	if c.live != nil && c.live.tracks(channel) {
+		// offline channels aren't meant to be joined
+		tracked, live := c.live.state(channel)
+		return !tracked || live
+	}
+	return true
+}
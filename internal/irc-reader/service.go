@@ -13,18 +13,41 @@ import (
 
 	"github.com/seventv/7tv-bot/internal/database"
 	"github.com/seventv/7tv-bot/internal/irc-reader/config"
+	"github.com/seventv/7tv-bot/pkg/helix"
 	"github.com/seventv/7tv-bot/pkg/manager"
+	"github.com/seventv/7tv-bot/pkg/publisher"
 	"github.com/seventv/7tv-bot/pkg/ratelimit"
+	"github.com/seventv/7tv-bot/pkg/sharding"
 )
 
 type Controller struct {
 	cfg       *config.Config
+	nc        *nats.Conn
 	jetStream nats.JetStreamContext
+	// publisher publishes messages from IRC to the jetStream in the background
+	publisher *publisher.Publisher
 	kube      *kubernetes.Clientset
 	twitch    *manager.IRCManager
+	// helix uses the app access token, userHelix uses the user access token, both are only set when needed
+	helix     *helix.Client
+	userHelix *helix.Client
+	// chat is only set when reading channels through EventSub is enabled
+	chat *chatSource
+	// live is only set when joining channels based on their stream status is enabled
+	live *liveTracker
+	// registry is only set when sharding through the membership registry is enabled,
+	// otherwise channels are sharded by shardID
+	registry *sharding.Registry
+	// balancer is only set when using the balanced sharding strategy
+	balancer *balancer
 
 	shardID int
 
+	// handoffSub receives channels from readers that are shutting down
+	handoffSub *nats.Subscription
+	// adopted holds the channels we adopted until their hold passed
+	adopted holds
+
 	// limit amount of workers for joining channels
 	joinSem chan struct{}
 }
@@ -37,7 +60,10 @@ func New(cfg *config.Config) *Controller {
 }
 
 func (c *Controller) Init() error {
-	if c.cfg.Replicas > 1 {
+	if c.cfg.Prometheus.Enabled {
+		c.serveMetrics()
+	}
+	if !c.cfg.Sharding.Enabled && c.cfg.Replicas > 1 {
 		c.shardID = getShardID()
 	}
 	nc, err := nats.Connect(c.cfg.Nats.URL)
@@ -46,6 +72,7 @@ func (c *Controller) Init() error {
 	}
 	// make sure all messages are actually written to NATS on shutdown
 	defer nc.Flush()
+	c.nc = nc
 
 	js, _ := nc.JetStream()
 
@@ -56,11 +83,23 @@ func (c *Controller) Init() error {
 
 	c.jetStream = js
 
+	err = c.initPublisher()
+	if err != nil {
+		return err
+	}
+
 	redisClient, err := c.initializeRedis()
 	if err != nil {
 		return err
 	}
 
+	if c.cfg.Sharding.Enabled {
+		err = c.initSharding(context.Background(), redisClient)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = c.kubeInit()
 	if err != nil {
 		return err
@@ -76,6 +115,7 @@ func (c *Controller) Init() error {
 
 	// initialize twitch IRC manager with ratelimit
 	c.twitch = manager.New(c.cfg.Twitch.User, oauth).
+		WithCapabilities(c.capabilities()...).
 		WithLimit(ratelimit.New(
 			redisClient,
 			c.cfg.RateLimit.Join,
@@ -99,6 +139,25 @@ func (c *Controller) Init() error {
 		}
 	}
 
+	err = c.initHelix(context.Background(), oauth)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.Live.Enabled {
+		err = c.initLive(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.cfg.Eventsub.Enabled {
+		err = c.initChat(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
 	// feed back twitch channels that got disconnected to the IRC
 	go c.handleOrphanedChannels()
 
@@ -107,14 +166,38 @@ func (c *Controller) Init() error {
 		return err
 	}
 
+	// subscribe before reading the channels, so we don't miss changes made while we're reading them
+	var changes *nats.Subscription
+	if !c.cfg.Changestream.Enabled {
+		changes, err = c.subscribeChanges()
+		if err != nil {
+			return err
+		}
+	}
+
 	database.GetChannels(
 		context.Background(),
 		c.joinChannels,
 		20,
 	)
 
-	// get changes to database over NATS
-	c.watchChanges(nc)
+	// get changes to database from the change stream, or from the API over NATS
+	if c.cfg.Changestream.Enabled {
+		go c.watchChangeStream(context.Background())
+	} else {
+		go c.watchChanges(context.Background(), changes)
+	}
+
+	if c.cfg.Handoff.Enabled {
+		err = c.handleHandoffs(nc)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.cfg.Reconcile.Interval > 0 {
+		go c.reconcileLoop(context.Background(), c.cfg.Reconcile.Interval)
+	}
 
 	return nil
 }
@@ -132,8 +215,33 @@ func getShardID() int {
 }
 
 func (c *Controller) Shutdown() {
+	if c.balancer != nil {
+		err := c.balancer.elector.Resign(context.Background())
+		if err != nil {
+			zap.S().Errorw("failed to resign leadership", "error", err)
+		}
+	}
+	if c.registry != nil {
+		// let the other readers take over our channels right away
+		err := c.registry.Leave(context.Background())
+		if err != nil {
+			zap.S().Errorw("failed to leave membership registry", "error", err)
+		}
+	}
+	if c.cfg.Handoff.Enabled {
+		c.drain()
+	}
 	wg := c.twitch.Shutdown()
 	wg.Wait()
+	if c.chat != nil {
+		c.chat.stop()
+	}
+	// publish what's left in the queue, anything we can't publish stays in the spill for the next start
+	c.publisher.Close()
+	err := c.nc.Flush()
+	if err != nil {
+		zap.S().Errorw("failed to flush NATS connection", "error", err)
+	}
 }
 
 func (c *Controller) initializeRedis() (*redis.Client, error) {
@@ -0,0 +1,145 @@
+package irc_reader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/internal/database"
+	"github.com/seventv/7tv-bot/pkg/sharding"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+var ErrUnknownShardingStrategy = errors.New("unknown sharding strategy")
+
+const (
+	shardingRendezvous = "rendezvous"
+	shardingBalanced   = "balanced"
+)
+
+// initSharding registers this reader in the membership registry. Channels are assigned to the registered readers
+// with rendezvous hashing, or by a leader-elected coordinator balancing their weight, and moved between readers as they join or leave.
+func (c *Controller) initSharding(ctx context.Context, client *redis.Client) error {
+	id, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	c.registry = sharding.NewRegistry(client, c.cfg.Sharding.Key, id, c.cfg.Sharding.TTL)
+	err = c.registry.Register(ctx)
+	if err != nil {
+		return err
+	}
+	zap.S().Infow("registered reader", "id", id, "members", c.registry.Members())
+
+	// changes are applied by a single goroutine, so rebalances never overlap & don't block the heartbeats
+	changed := make(chan struct{}, 1)
+	c.registry.OnChange(func(previous, current []string) {
+		zap.S().Infow("reader membership changed", "previous", previous, "current", current)
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	switch c.cfg.Sharding.Strategy {
+	case shardingRendezvous, "":
+		go c.rebalanceLoop(ctx, changed, c.registry.Members())
+	case shardingBalanced:
+		err = c.initBalancer(ctx, client, changed)
+		if err != nil {
+			return err
+		}
+	default:
+		return ErrUnknownShardingStrategy
+	}
+
+	go c.registry.Start(ctx, c.cfg.Sharding.Interval)
+	return nil
+}
+
+// rebalanceLoop rebalances channels from the last applied members to the current members on every change
+func (c *Controller) rebalanceLoop(ctx context.Context, changed <-chan struct{}, applied []string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			current := c.registry.Members()
+			c.rebalance(ctx, rendezvousOwners(applied), rendezvousOwners(current))
+			applied = current
+		}
+	}
+}
+
+// ownersFunc returns the readers a channel is assigned to, replicas is the amount of readers that should read it
+type ownersFunc func(key string, replicas int) []string
+
+// rebalance joins the channels assigned to us after an assignment change, and parts the channels we no longer own.
+// Channels that keep their owner are left alone.
+func (c *Controller) rebalance(ctx context.Context, previous, current ownersFunc) {
+	id := c.registry.ID()
+	err := database.GetChannels(ctx, func(channels []types.Channel) {
+		for _, channel := range channels {
+			key := strconv.FormatInt(channel.ID, 10)
+			owned := contains(previous(key, channel.Replicas), id)
+			owns := contains(current(key, channel.Replicas), id)
+			switch {
+			case owns && !owned:
+				c.joinChannel(channel)
+			case owned && !owns:
+				c.partChannel(channel)
+			}
+		}
+	}, 20)
+	if err != nil {
+		zap.S().Errorw("failed to rebalance channels", "error", err)
+	}
+}
+
+// ownsChannel returns true if the channel is assigned to this reader, or this reader is one of its replicas
+func (c *Controller) ownsChannel(channel types.Channel) bool {
+	key := strconv.FormatInt(channel.ID, 10)
+	members := c.registry.Members()
+	if c.balancer != nil {
+		return contains(c.balancer.owners(key, channel.Replicas, members), c.registry.ID())
+	}
+	return contains(sharding.Owners(key, members, channel.Replicas), c.registry.ID())
+}
+
+func rendezvousOwners(members []string) ownersFunc {
+	return func(key string, replicas int) []string {
+		return sharding.Owners(key, members, replicas)
+	}
+}
+
+// withReplicas adds the replicas of a channel to its owner, they're the next readers by rendezvous hashing.
+// The replicas aren't part of the balanced assignment, they're only there for redundancy.
+func withReplicas(owner, key string, members []string, replicas int) []string {
+	owners := []string{owner}
+	if replicas <= 1 {
+		return owners
+	}
+	for _, member := range sharding.Owners(key, members, replicas) {
+		if len(owners) == replicas {
+			break
+		}
+		if member != owner {
+			owners = append(owners, member)
+		}
+	}
+	return owners
+}
+
+func contains(members []string, id string) bool {
+	for _, member := range members {
+		if member == id {
+			return true
+		}
+	}
+	return false
+}
@@ -1,49 +1,91 @@
 package irc_reader
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 
 	"github.com/seventv/7tv-bot/pkg/bitwise"
 	"github.com/seventv/7tv-bot/pkg/irc"
+	"github.com/seventv/7tv-bot/pkg/manager"
 	"github.com/seventv/7tv-bot/pkg/types"
 )
 
 func (c *Controller) onMessage(msg *irc.Message, err error) {
 	if msg.GetType() == irc.Notice {
 		zap.S().Info(fmt.Sprintf("NOTICE from twitch IRC: %v", msg.String()))
+	}
+	if !c.publishes(msg.GetType()) {
+		return
+	}
+	receivedAt := time.Now()
+
+	command, channel := parseCommand(msg.String())
+	// skip anything that's not sent to a channel
+	if channel == "" {
 		return
 	}
-	// skip anything that's not a channel message
-	if msg.GetType() != irc.PrivMessage {
+	// we're only interested in our own memberships
+	if (msg.GetType() == irc.Join || msg.GetType() == irc.Part) && parseSender(msg.String()) != c.cfg.Twitch.User {
 		return
 	}
 
 	// publish message to nats topic
 	subject := c.cfg.Nats.Topic.Raw
-	subject += ".privmsg." + parseChannel(msg.String())
+	subject += "." + strings.ToLower(command) + "." + channel
 
 	// set message ID as header, so we can filter out duplicate messages with JetStream
+	id := msg.MessageID()
 	header := nats.Header{}
-	header.Add("Nats-Msg-Id", parseMessageId(msg.String()))
+	if id != "" {
+		header.Add("Nats-Msg-Id", id)
+	}
 
 	zap.S().Debugln(fmt.Sprintf("publishing to NATS: %v", msg.String()))
 
-	_, err = c.jetStream.PublishMsg(&nats.Msg{
+	c.publisher.Publish(&nats.Msg{
 		Subject: subject,
 		Header:  header,
 		Data:    []byte(msg.String()),
 	})
 
-	if err != nil {
-		zap.L().Error(
-			"failed publish to NATS",
-			zap.String("error", err.Error()),
-		)
+	if msg.GetType() == irc.PrivMessage {
+		c.publishNormalized(msg.String(), id, receivedAt)
+	}
+}
+
+// publishes returns true if messages of this type should be published to NATS, defaults to only PRIVMSG
+func (c *Controller) publishes(messageType irc.MessageType) bool {
+	command := messageType.Command()
+	if command == "" {
+		return false
+	}
+	if len(c.cfg.Nats.Commands) == 0 {
+		return messageType == irc.PrivMessage
+	}
+	for _, configured := range c.cfg.Nats.Commands {
+		if strings.EqualFold(configured, command) {
+			return true
+		}
 	}
+	return false
+}
+
+// capabilities returns the twitch-irc capabilities needed for the configured commands
+func (c *Controller) capabilities() []string {
+	for _, command := range c.cfg.Nats.Commands {
+		switch strings.ToUpper(command) {
+		case "PRIVMSG", "JOIN", "PART":
+		default:
+			return []string{irc.CapTags, irc.CapCommands}
+		}
+	}
+	return []string{irc.CapTags}
 }
 
 func (c *Controller) handleOrphanedChannels() {
@@ -71,20 +113,71 @@ func (c *Controller) joinChannels(channels []types.Channel) {
 }
 
 func (c *Controller) joinChannel(channel types.Channel) {
-	if !c.shouldJoin(channel.ID) {
+	if !c.shouldJoin(channel) {
 		return
 	}
-	c.joinSem <- struct{}{}
-	ch := channel
-	go func() {
-		// make sure the channel is flagged to be joined
-		if !bitwise.Has(ch.Flags, bitwise.JOIN_IRC) {
+
+	if c.chat != nil {
+		if bitwise.Has(channel.Flags, bitwise.JOIN_EVENTSUB) && c.joinEventSub(channel) {
 			return
 		}
+		// the channel could've been moved back to IRC
+		c.chat.remove(context.TODO(), channel)
+	}
+
+	// make sure the channel is flagged to be joined
+	if !bitwise.Has(channel.Flags, bitwise.JOIN_IRC) {
+		return
+	}
 
+	if c.live != nil {
+		// channels below the weight threshold are joined by the live tracker once they're live
+		if c.live.tracks(channel) && c.live.add(channel) {
+			return
+		}
+		// the weight could've been raised above the threshold
+		c.live.remove(channel)
+	}
+
+	c.join(channel)
+}
+
+// joinEventSub reads the channel through EventSub, and parts it on IRC in case it was moved from IRC.
+// Returns false if the subscription failed, so we can fall back to IRC.
+func (c *Controller) joinEventSub(channel types.Channel) bool {
+	err := c.chat.add(context.TODO(), channel)
+	if err != nil {
+		zap.L().Error(
+			"failed to subscribe to chat through eventsub",
+			zap.String("error", err.Error()),
+			zap.String("channel", channel.Username),
+		)
+		return false
+	}
+
+	if c.live != nil {
+		c.live.remove(channel)
+	}
+	err = c.twitch.Part(channel.Username)
+	if err != nil && !errors.Is(err, manager.ErrChanNotFound) {
+		zap.L().Error(
+			"failed to part channel moved to eventsub",
+			zap.String("error", err.Error()),
+			zap.String("channel", channel.Username),
+		)
+	}
+	return true
+}
+
+// join joins the channel without checking whether it should be joined
+func (c *Controller) join(channel types.Channel) {
+	c.joinSem <- struct{}{}
+	ch := channel
+	go func() {
 		zap.S().Infof("joining channel: %v", ch.Username)
 		err := c.twitch.Join(ch.Username, ch.Weight)
-		if err != nil {
+		// channels can be joined already when we receive an update for them
+		if err != nil && !errors.Is(err, manager.ErrChanAlreadyJoined) {
 			zap.L().Error(
 				"failed to join channel",
 				zap.String("error", err.Error()),
@@ -95,26 +188,107 @@ func (c *Controller) joinChannel(channel types.Channel) {
 	}()
 }
 
-func (c *Controller) shouldJoin(userID int64) bool {
+// partChannel parts the channel, stops tracking its stream status & removes its EventSub subscription
+func (c *Controller) partChannel(channel types.Channel) {
+	if c.live != nil {
+		c.live.remove(channel)
+	}
+	if c.chat != nil {
+		err := c.chat.remove(context.TODO(), channel)
+		if err != nil {
+			zap.L().Error(
+				"failed to unsubscribe from chat through eventsub",
+				zap.String("error", err.Error()),
+				zap.String("channel", channel.Username),
+			)
+		}
+	}
+	err := c.twitch.Part(channel.Username)
+	if err != nil && !errors.Is(err, manager.ErrChanNotFound) {
+		zap.L().Error(
+			"failed to part channel",
+			zap.String("error", err.Error()),
+			zap.String("channel", channel.Username),
+		)
+	}
+}
+
+// updateChannel parts the previous username of a renamed channel, and joins or parts the channel according to its flags
+func (c *Controller) updateChannel(channel types.Channel) {
+	if len(channel.UsernameHistory) > 0 {
+		previous := channel.UsernameHistory[len(channel.UsernameHistory)-1].Username
+		if previous != channel.Username {
+			err := c.twitch.Part(previous)
+			if err != nil && !errors.Is(err, manager.ErrChanNotFound) {
+				zap.L().Error(
+					"failed to part renamed channel",
+					zap.String("error", err.Error()),
+					zap.String("channel", previous),
+				)
+			}
+		}
+	}
+
+	if !bitwise.Has(channel.Flags, bitwise.JOIN_IRC) && !bitwise.Has(channel.Flags, bitwise.JOIN_EVENTSUB) {
+		c.partChannel(channel)
+		return
+	}
+	c.joinChannel(channel)
+}
+
+// shouldJoin returns true if the channel is assigned to this reader. Channels with replicas are read by multiple readers,
+// the duplicate messages are filtered out by JetStream.
+func (c *Controller) shouldJoin(channel types.Channel) bool {
+	if c.registry != nil {
+		return c.ownsChannel(channel)
+	}
+
 	if c.cfg.Replicas < 2 {
 		return true
 	}
 
-	if int(userID)%c.cfg.Replicas == c.shardID {
-		return true
+	// replicas are assigned to the next shards
+	replicas := channel.Replicas
+	if replicas < 1 {
+		replicas = 1
+	}
+	for i := 0; i < replicas && i < c.cfg.Replicas; i++ {
+		if (int(channel.ID)+i)%c.cfg.Replicas == c.shardID {
+			return true
+		}
 	}
 
 	return false
 }
 
-// parses out the channel name from a PRIVMSG,
-// don't use on any other type of message seen as though there's no slice length checks
-func parseChannel(in string) string {
-	return strings.TrimPrefix(strings.Split(strings.Split(in, "PRIVMSG")[1], " ")[1], "#")
+// parses out the command & channel of a message with optional tags & prefix,
+// channel is empty if the message isn't sent to a channel
+func parseCommand(in string) (command, channel string) {
+	rest := in
+	if strings.HasPrefix(rest, "@") {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	if strings.HasPrefix(rest, ":") {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	command, rest, _ = strings.Cut(rest, " ")
+	param, _, _ := strings.Cut(rest, " ")
+	if strings.HasPrefix(param, "#") {
+		channel = param[1:]
+	}
+	return command, channel
 }
 
-// parses out the message ID from a PRIVMSG with optional tags
-// don't use on any other type of message seen as though there's no slice length checks
-func parseMessageId(in string) string {
-	return strings.Split(strings.Split(in, ";id=")[1], ";")[0]
+// parses out the login of the sender from the message prefix, empty if there's no prefix
+func parseSender(in string) string {
+	rest := in
+	if strings.HasPrefix(rest, "@") {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return ""
+	}
+	prefix, _, _ := strings.Cut(rest[1:], " ")
+	login, _, _ := strings.Cut(prefix, "!")
+	return login
 }
@@ -1,54 +1,114 @@
 package irc_reader
 
-import "testing"
+import (
+	"testing"
 
-func Test_parseChannel(t *testing.T) {
+	"github.com/seventv/7tv-bot/internal/irc-reader/config"
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+func Test_parseCommand(t *testing.T) {
 	type args struct {
 		in string
 	}
 	tests := []struct {
-		name string
-		args args
-		want string
+		name        string
+		args        args
+		wantCommand string
+		wantChannel string
 	}{
 		{
 			name: "privmsg+tags",
 			args: args{
 				in: "@badge-info=subscriber/57;badges=moderator/1,subscriber/3054,partner/1;color=#1976D2;display-name=Fossabot;emotes=;first-msg=0;flags=;id=23ebb86b-f9fa-47b8-893c-708587661afc;mod=1;returning-chatter=0;room-id=26301881;subscriber=1;tmi-sent-ts=1690815698066;turbo=0;user-id=237719657;user-type=mod :fossabot!fossabot@fossabot.tmi.twitch.tv PRIVMSG #sodapoppin : ( ° ͜ʖ͡°)╭∩╮",
 			},
-			want: "sodapoppin",
+			wantCommand: "PRIVMSG",
+			wantChannel: "sodapoppin",
+		},
+		{
+			name: "clearchat",
+			args: args{
+				in: "@room-id=12345678;target-user-id=87654321;tmi-sent-ts=1642715756806 :tmi.twitch.tv CLEARCHAT #dallas :ronni",
+			},
+			wantCommand: "CLEARCHAT",
+			wantChannel: "dallas",
+		},
+		{
+			name: "join",
+			args: args{
+				in: ":7tvbot!7tvbot@7tvbot.tmi.twitch.tv JOIN #dallas",
+			},
+			wantCommand: "JOIN",
+			wantChannel: "dallas",
+		},
+		{
+			name: "notice without channel",
+			args: args{
+				in: ":tmi.twitch.tv NOTICE * :Login authentication failed",
+			},
+			wantCommand: "NOTICE",
+			wantChannel: "",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := parseChannel(tt.args.in); got != tt.want {
-				t.Errorf("parseChannel() = %v, want %v", got, tt.want)
+			command, channel := parseCommand(tt.args.in)
+			if command != tt.wantCommand || channel != tt.wantChannel {
+				t.Errorf("parseCommand() = %v, %v, want %v, %v", command, channel, tt.wantCommand, tt.wantChannel)
 			}
 		})
 	}
 }
 
-func Test_parseMessageId(t *testing.T) {
-	type args struct {
-		in string
-	}
+func TestController_shouldJoin(t *testing.T) {
 	tests := []struct {
-		name string
-		args args
-		want string
+		name    string
+		shardID int
+		channel types.Channel
+		want    bool
 	}{
 		{
-			name: "privmsg+tags",
-			args: args{
-				in: "@badge-info=subscriber/57;badges=moderator/1,subscriber/3054,partner/1;color=#1976D2;display-name=Fossabot;emotes=;first-msg=0;flags=;id=23ebb86b-f9fa-47b8-893c-708587661afc;mod=1;returning-chatter=0;room-id=26301881;subscriber=1;tmi-sent-ts=1690815698066;turbo=0;user-id=237719657;user-type=mod :fossabot!fossabot@fossabot.tmi.twitch.tv PRIVMSG #sodapoppin : ( ° ͜ʖ͡°)╭∩╮",
-			},
-			want: "23ebb86b-f9fa-47b8-893c-708587661afc",
+			name:    "own shard",
+			shardID: 1,
+			channel: types.Channel{ID: 4},
+			want:    true,
+		},
+		{
+			name:    "other shard",
+			shardID: 2,
+			channel: types.Channel{ID: 4},
+			want:    false,
+		},
+		{
+			name:    "replica on next shard",
+			shardID: 2,
+			channel: types.Channel{ID: 4, Replicas: 2},
+			want:    true,
+		},
+		{
+			name:    "replica wraps around",
+			shardID: 0,
+			channel: types.Channel{ID: 5, Replicas: 2},
+			want:    true,
+		},
+		{
+			name:    "not a replica",
+			shardID: 0,
+			channel: types.Channel{ID: 4, Replicas: 2},
+			want:    false,
+		},
+		{
+			name:    "more replicas than readers",
+			shardID: 0,
+			channel: types.Channel{ID: 4, Replicas: 5},
+			want:    true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := parseMessageId(tt.args.in); got != tt.want {
-				t.Errorf("parseMessageId() = %v, want %v", got, tt.want)
+			c := &Controller{cfg: &config.Config{Replicas: 3}, shardID: tt.shardID}
+			if got := c.shouldJoin(tt.channel); got != tt.want {
+				t.Errorf("shouldJoin() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -31,3 +31,18 @@ func (s *Service) setKubeSecret(ctx context.Context, auth *OauthResponse) error
 	_, err := s.kube.CoreV1().Secrets(s.cfg.Kube.Namespace).Apply(ctx, secret, opts)
 	return err
 }
+
+// setKubeAppSecret stores the app access token in the same secret as the user token.
+// It uses its own field manager, so applying it doesn't remove the keys owned by setKubeSecret and vice versa.
+func (s *Service) setKubeAppSecret(ctx context.Context, auth *OauthResponse) error {
+	secret := v1.Secret(s.cfg.Kube.Oauthsecret, s.cfg.Kube.Namespace).
+		WithType("Opaque")
+	secret.StringData = make(map[string]string)
+	secret.StringData["app-access-token"] = auth.AccessToken
+
+	opts := metav1.ApplyOptions{}
+	opts.FieldManager = "7tv-auth-app"
+
+	_, err := s.kube.CoreV1().Secrets(s.cfg.Kube.Namespace).Apply(ctx, secret, opts)
+	return err
+}
@@ -54,6 +54,9 @@ func (s *Service) Init() {
 	}
 	zap.S().Info("connected to kubernetes API")
 
+	// the app access token doesn't need user authorization, so we can keep it rotated independently of the user token
+	go s.appTokenLoop()
+
 	// check kubernetes for existing refresh token
 	secret, err := s.kube.CoreV1().Secrets(s.cfg.Kube.Namespace).Get(
 		context.TODO(),
@@ -118,6 +121,28 @@ func (s *Service) refreshLoop() {
 	}
 }
 
+func (s *Service) appTokenLoop() {
+	for {
+		auth, err := s.getAppToken()
+		if err != nil {
+			zap.S().Errorw("failed to get app access token", "error", err)
+			// wait a minute then try again
+			<-time.NewTimer(1 * time.Minute).C
+			continue
+		}
+		err = s.setKubeAppSecret(context.TODO(), auth)
+		if err != nil {
+			zap.S().Errorw("failed to store app access token in kube secret", "error", err)
+			<-time.NewTimer(1 * time.Minute).C
+			continue
+		}
+		zap.S().Infof("pushed app access token to kube secret, expires in %vs", auth.ExpiresIn)
+
+		// wait to request a new token until 70% of the expiry duration passed
+		<-time.NewTimer(time.Duration(auth.ExpiresIn*7/10) * time.Second).C
+	}
+}
+
 func (s *Service) setToken(auth *OauthResponse) error {
 	s.lastOauth = auth
 	return s.setKubeSecret(context.TODO(), auth)
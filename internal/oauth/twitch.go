@@ -56,6 +56,24 @@ func (s *Service) refreshToken() (*OauthResponse, error) {
 	return response, err
 }
 
+// getAppToken requests an app access token using the client credentials grant,
+// app access tokens can't be refreshed, so we request a new one when the current one is about to expire
+func (s *Service) getAppToken() (*OauthResponse, error) {
+	data := url.Values{}
+	data.Set("client_id", s.cfg.Twitch.Clientid)
+	data.Set("client_secret", s.cfg.Twitch.Clientsecret)
+	data.Set("grant_type", "client_credentials")
+
+	body, err := postData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &OauthResponse{}
+	err = json.Unmarshal(body, response)
+	return response, err
+}
+
 func postData(data url.Values) ([]byte, error) {
 	res, err := http.PostForm("https://id.twitch.tv/oauth2/token", data)
 	if err != nil {
@@ -71,7 +89,7 @@ func postData(data url.Values) ([]byte, error) {
 
 func (s *Service) generateUri() string {
 	return fmt.Sprintf(
-		"https://id.twitch.tv/oauth2/authorize?response_type=code&client_id=%v&redirect_uri=%v&scope=chat%%3Aread%%20chat%%3Aedit&state=%v",
+		"https://id.twitch.tv/oauth2/authorize?response_type=code&client_id=%v&redirect_uri=%v&scope=chat%%3Aread%%20chat%%3Aedit%%20user%%3Aread%%3Achat&state=%v",
 		s.cfg.Twitch.Clientid,
 		s.cfg.Twitch.Redirecturi,
 		s.cfg.Twitch.State)
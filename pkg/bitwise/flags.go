@@ -2,5 +2,7 @@ package bitwise
 
 const (
 	JOIN_IRC uint32 = 1 << iota
+	// JOIN_EVENTSUB reads the channel through EventSub instead of IRC, on readers that have EventSub enabled
+	JOIN_EVENTSUB
 	// TODO: add other flags
 )
@@ -50,3 +50,8 @@ func EnsureCollection(coll string, indexes []mongo.IndexModel) *mongo.Collection
 	collection.Indexes().CreateMany(ctx, indexes)
 	return collection
 }
+
+// Collection returns the collection without creating it or its indexes, for collections that are owned by another service
+func Collection(coll string) *mongo.Collection {
+	return db.Collection(coll)
+}
@@ -0,0 +1,157 @@
+package emotes
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Granularity is the size of the time buckets emote usage is counted in
+type Granularity int
+
+const (
+	Minute Granularity = iota
+	Hour
+	Day
+)
+
+// Duration returns the length of a bucket, buckets start at multiples of it since the zero time, so days start at midnight UTC
+func (g Granularity) Duration() time.Duration {
+	switch g {
+	case Hour:
+		return time.Hour
+	case Day:
+		return 24 * time.Hour
+	default:
+		return time.Minute
+	}
+}
+
+func (g Granularity) String() string {
+	switch g {
+	case Hour:
+		return "hour"
+	case Day:
+		return "day"
+	default:
+		return "minute"
+	}
+}
+
+// IncrementBuckets increments the minute buckets of the emotes in a single unordered bulk write,
+// the hour & day buckets are filled in by Rollup
+func IncrementBuckets(ctx context.Context, buckets []UsageBucket) error {
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(buckets))
+	for _, bucket := range buckets {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{
+				{"emote_id", bucket.EmoteID},
+				{"channel_id", bucket.ChannelID},
+				{"bucket", bucket.Bucket.Truncate(Minute.Duration())},
+			}).
+			SetUpdate(bson.M{"$inc": bson.M{"count": bucket.Count}}).
+			SetUpsert(true))
+	}
+
+	_, err := collections.Minutely.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// Rollup recomputes the buckets of granularity to from the buckets of granularity from, starting at the bucket since is in.
+// The buckets are replaced instead of incremented, so rollups can be repeated & run by multiple aggregators at once.
+// The from buckets must still be retained for the whole range, or the rolled up counts lose what expired.
+func Rollup(ctx context.Context, from, to Granularity, since time.Time) error {
+	size := to.Duration().Milliseconds()
+	cursor, err := collections.buckets(from).Aggregate(ctx, mongo.Pipeline{
+		{{"$match", bson.D{{"bucket", bson.D{{"$gte", since.Truncate(to.Duration())}}}}}},
+		{{"$group", bson.D{
+			{"_id", bson.D{
+				// truncates the bucket to the larger granularity
+				{"bucket", bson.D{{"$subtract", bson.A{"$bucket", bson.D{{"$mod", bson.A{bson.D{{"$toLong", "$bucket"}}, size}}}}}}},
+				{"emote_id", "$emote_id"},
+				{"channel_id", "$channel_id"},
+			}},
+			{"count", bson.D{{"$sum", "$count"}}},
+		}}},
+		{{"$project", bson.D{
+			{"_id", 0},
+			{"bucket", "$_id.bucket"},
+			{"emote_id", "$_id.emote_id"},
+			{"channel_id", "$_id.channel_id"},
+			{"count", 1},
+		}}},
+		{{"$merge", bson.D{
+			{"into", collections.buckets(to).Name()},
+			{"on", bson.A{"emote_id", "channel_id", "bucket"}},
+			{"whenMatched", "replace"},
+			{"whenNotMatched", "insert"},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// Usage returns the usage of an emote per bucket in [start, end), oldest first.
+// The usage is summed across all channels if channelID is empty.
+func Usage(ctx context.Context, granularity Granularity, emoteID primitive.ObjectID, channelID string, start, end time.Time) ([]UsageBucket, error) {
+	match := bson.D{
+		{"emote_id", emoteID},
+		{"bucket", bson.D{{"$gte", start}, {"$lt", end}}},
+	}
+	if channelID != "" {
+		match = append(match, bson.E{"channel_id", channelID})
+	}
+
+	cursor, err := collections.buckets(granularity).Aggregate(ctx, mongo.Pipeline{
+		{{"$match", match}},
+		{{"$group", bson.D{{"_id", "$bucket"}, {"count", bson.D{{"$sum", "$count"}}}}}},
+		{{"$sort", bson.D{{"_id", 1}}}},
+		{{"$project", bson.D{
+			{"_id", 0},
+			{"bucket", "$_id"},
+			{"emote_id", emoteID},
+			{"channel_id", channelID},
+			{"count", 1},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []UsageBucket{}
+	err = cursor.All(ctx, &result)
+	return result, err
+}
+
+// TopEmotes returns the most used emotes in [start, end), most used first.
+// The usage is summed across all channels if channelID is empty.
+func TopEmotes(ctx context.Context, granularity Granularity, channelID string, start, end time.Time, limit int64) ([]EmoteUsage, error) {
+	match := bson.D{{"bucket", bson.D{{"$gte", start}, {"$lt", end}}}}
+	if channelID != "" {
+		match = append(match, bson.E{"channel_id", channelID})
+	}
+
+	cursor, err := collections.buckets(granularity).Aggregate(ctx, mongo.Pipeline{
+		{{"$match", match}},
+		{{"$group", bson.D{{"_id", "$emote_id"}, {"count", bson.D{{"$sum", "$count"}}}}}},
+		{{"$sort", bson.D{{"count", -1}, {"_id", 1}}}},
+		{{"$limit", limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []EmoteUsage{}
+	err = cursor.All(ctx, &result)
+	return result, err
+}
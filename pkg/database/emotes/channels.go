@@ -0,0 +1,65 @@
+package emotes
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+// ChannelEmote is the count of an emote in a single channel
+type ChannelEmote struct {
+	ChannelID string
+	Emote     types.CountedEmote
+}
+
+// IncrementChannelEmotes increments the counts of the emotes per channel in a single unordered bulk write
+func IncrementChannelEmotes(ctx context.Context, emotes []ChannelEmote) error {
+	if len(emotes) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	models := make([]mongo.WriteModel, 0, len(emotes))
+	for _, emote := range emotes {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{"channel_id", emote.ChannelID}, {"emote_id", emote.Emote.Emote.EmoteID}}).
+			SetUpdate(bson.M{
+				"$setOnInsert": bson.M{
+					"created_at": now,
+				},
+				// the emote can be renamed in the channel, so keep its data up to date
+				"$set": bson.M{
+					"name":       emote.Emote.Emote.Name,
+					"flags":      emote.Emote.Emote.Flags,
+					"url":        emote.Emote.Emote.URL,
+					"updated_at": now,
+				},
+				"$inc": bson.M{"count": emote.Emote.Count},
+			}).
+			SetUpsert(true))
+	}
+
+	_, err := collections.ChannelStats.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// TopChannelEmotes returns the most used emotes in the channel, most used first
+func TopChannelEmotes(ctx context.Context, channelID string, limit int64) ([]ChannelEmoteCount, error) {
+	cursor, err := collections.ChannelStats.Find(
+		ctx,
+		bson.D{{"channel_id", channelID}},
+		options.Find().SetSort(bson.D{{"count", -1}}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []ChannelEmoteCount{}
+	err = cursor.All(ctx, &result)
+	return result, err
+}
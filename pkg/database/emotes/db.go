@@ -6,10 +6,35 @@ import (
 
 type Collections struct {
 	GlobalStats *mongo.Collection
+	// ChannelStats holds the counts of emotes per channel
+	ChannelStats *mongo.Collection
+	// Processed holds the keys of messages that were counted, so redelivered messages aren't counted twice
+	Processed *mongo.Collection
+	// UserStats holds the counts of emotes per chatter, optionally per channel
+	UserStats *mongo.Collection
+	// OptOuts holds the chatters whose emotes aren't counted per user
+	OptOuts *mongo.Collection
+	// Trending holds the trending emotes globally & per channel
+	Trending *mongo.Collection
+	// Minutely, Hourly & Daily hold the counts of emotes per channel & time bucket of their granularity
+	Minutely *mongo.Collection
+	Hourly   *mongo.Collection
+	Daily    *mongo.Collection
 }
 
 var collections Collections
 
-func SetCollections(globalStats *mongo.Collection) {
-	collections.GlobalStats = globalStats
+func SetCollections(c Collections) {
+	collections = c
+}
+
+func (c Collections) buckets(granularity Granularity) *mongo.Collection {
+	switch granularity {
+	case Hour:
+		return c.Hourly
+	case Day:
+		return c.Daily
+	default:
+		return c.Minutely
+	}
 }
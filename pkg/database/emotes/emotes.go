@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/seventv/7tv-bot/pkg/types"
@@ -46,3 +47,90 @@ func IncrementEmote(ctx context.Context, emote types.CountedEmote) error {
 
 	return err
 }
+
+// IncrementEmotes increments the counts of the emotes in a single unordered bulk write, emotes that don't exist yet are inserted
+func IncrementEmotes(ctx context.Context, emotes []types.CountedEmote) error {
+	if len(emotes) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	models := make([]mongo.WriteModel, 0, len(emotes))
+	for _, emote := range emotes {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{"emote_id", emote.Emote.EmoteID}}).
+			SetUpdate(bson.M{
+				"$setOnInsert": bson.M{
+					"name":       emote.Emote.Name,
+					"emote_id":   emote.Emote.EmoteID,
+					"flags":      emote.Emote.Flags,
+					"state":      emote.Emote.State,
+					"url":        emote.Emote.URL,
+					"created_at": now,
+				},
+				"$inc": bson.M{"count": emote.Count},
+				"$set": bson.M{"updated_at": now},
+			}).
+			SetUpsert(true))
+	}
+
+	_, err := collections.GlobalStats.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// WithTransaction runs fn in a transaction, the driver retries it on transient errors. Requires a replica set.
+func WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := collections.GlobalStats.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// Processed returns which of the message keys were already counted
+func Processed(ctx context.Context, keys []string) (map[string]bool, error) {
+	processed := make(map[string]bool)
+	if len(keys) == 0 {
+		return processed, nil
+	}
+
+	cursor, err := collections.Processed.Find(
+		ctx,
+		bson.D{{"_id", bson.D{{"$in", keys}}}},
+		options.Find().SetProjection(bson.D{{"_id", 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		doc := ProcessedMessage{}
+		err = cursor.Decode(&doc)
+		if err != nil {
+			return nil, err
+		}
+		processed[doc.Key] = true
+	}
+	return processed, cursor.Err()
+}
+
+// MarkProcessed records the message keys as counted, they expire through the TTL index on created_at
+func MarkProcessed(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	docs := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		docs = append(docs, ProcessedMessage{Key: key, CreatedAt: now})
+	}
+	_, err := collections.Processed.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
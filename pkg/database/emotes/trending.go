@@ -0,0 +1,93 @@
+package emotes
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RangeCounts returns the usage of every emote per channel summed over the buckets in [start, end),
+// the Bucket of the results is start
+func RangeCounts(ctx context.Context, granularity Granularity, start, end time.Time) ([]UsageBucket, error) {
+	cursor, err := collections.buckets(granularity).Aggregate(ctx, mongo.Pipeline{
+		{{"$match", bson.D{{"bucket", bson.D{{"$gte", start}, {"$lt", end}}}}}},
+		{{"$group", bson.D{
+			{"_id", bson.D{{"emote_id", "$emote_id"}, {"channel_id", "$channel_id"}}},
+			{"count", bson.D{{"$sum", "$count"}}},
+		}}},
+		{{"$project", bson.D{
+			{"_id", 0},
+			{"bucket", start},
+			{"emote_id", "$_id.emote_id"},
+			{"channel_id", "$_id.channel_id"},
+			{"count", 1},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []UsageBucket{}
+	err = cursor.All(ctx, &result)
+	return result, err
+}
+
+// GetEmotes returns the global stats of the emotes by their ID, emotes that were never counted are missing
+func GetEmotes(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]EmoteCount, error) {
+	emotes := make(map[primitive.ObjectID]EmoteCount)
+	if len(ids) == 0 {
+		return emotes, nil
+	}
+
+	cursor, err := collections.GlobalStats.Find(ctx, bson.D{{"emote_id", bson.D{{"$in", ids}}}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		emote := EmoteCount{}
+		err = cursor.Decode(&emote)
+		if err != nil {
+			return nil, err
+		}
+		emotes[emote.EmoteID] = emote
+	}
+	return emotes, cursor.Err()
+}
+
+// SaveTrending replaces the trending lists in a single unordered bulk write,
+// and deletes the lists that weren't computed at the same time, e.g. of channels nobody is chatting in anymore
+func SaveTrending(ctx context.Context, lists []TrendingList, computedAt time.Time) error {
+	if len(lists) > 0 {
+		models := make([]mongo.WriteModel, 0, len(lists))
+		for _, list := range lists {
+			list.ComputedAt = computedAt
+			models = append(models, mongo.NewReplaceOneModel().
+				SetFilter(bson.D{{"_id", list.ChannelID}}).
+				SetReplacement(list).
+				SetUpsert(true))
+		}
+		_, err := collections.Trending.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := collections.Trending.DeleteMany(ctx, bson.D{{"computed_at", bson.D{{"$lt", computedAt}}}})
+	return err
+}
+
+// GetTrending returns the trending emotes of the channel, or the global trending emotes if channelID is empty
+func GetTrending(ctx context.Context, channelID string) (*TrendingList, error) {
+	list := &TrendingList{}
+	err := collections.Trending.FindOne(ctx, bson.D{{"_id", channelID}}).Decode(list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -22,3 +22,73 @@ type EmoteCount struct {
 	UpdatedAt time.Time                  `bson:"updated_at"`
 	Count     int                        `bson:"count"`
 }
+
+// ChannelEmoteCount is the count of an emote in a single channel
+type ChannelEmoteCount struct {
+	ChannelID string                     `bson:"channel_id" json:"channel_id"`
+	EmoteID   primitive.ObjectID         `bson:"emote_id" json:"emote_id"`
+	Name      string                     `bson:"name" json:"name"`
+	Flags     model.ActiveEmoteFlagModel `bson:"flags" json:"flags"`
+	URL       string                     `bson:"url" json:"url"`
+	CreatedAt time.Time                  `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time                  `bson:"updated_at" json:"updated_at"`
+	Count     int                        `bson:"count" json:"count"`
+}
+
+// UserEmoteCount is the count of an emote used by a single chatter, ChannelID is empty if it's counted across all channels
+type UserEmoteCount struct {
+	UserID    string                     `bson:"user_id" json:"user_id"`
+	ChannelID string                     `bson:"channel_id" json:"channel_id,omitempty"`
+	EmoteID   primitive.ObjectID         `bson:"emote_id" json:"emote_id"`
+	Name      string                     `bson:"name" json:"name"`
+	Flags     model.ActiveEmoteFlagModel `bson:"flags" json:"flags"`
+	URL       string                     `bson:"url" json:"url"`
+	CreatedAt time.Time                  `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time                  `bson:"updated_at" json:"updated_at"`
+	Count     int                        `bson:"count" json:"count"`
+}
+
+// OptOutUser is a chatter whose emotes aren't counted per user
+type OptOutUser struct {
+	UserID    string    `bson:"_id"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// UsageBucket is the usage of an emote in a channel during a single time bucket
+type UsageBucket struct {
+	Bucket    time.Time          `bson:"bucket" json:"bucket"`
+	EmoteID   primitive.ObjectID `bson:"emote_id" json:"emote_id"`
+	ChannelID string             `bson:"channel_id" json:"channel_id"`
+	Count     int                `bson:"count" json:"count"`
+}
+
+// EmoteUsage is the usage of an emote during a range of buckets
+type EmoteUsage struct {
+	EmoteID primitive.ObjectID `bson:"_id" json:"emote_id"`
+	Count   int                `bson:"count" json:"count"`
+}
+
+// TrendingList is the ranked list of trending emotes of a channel, ChannelID is empty for the global list
+type TrendingList struct {
+	ChannelID  string          `bson:"_id" json:"channel_id,omitempty"`
+	ComputedAt time.Time       `bson:"computed_at" json:"computed_at"`
+	Emotes     []TrendingEmote `bson:"emotes" json:"emotes"`
+}
+
+// TrendingEmote is an emote that's used more than usual, Recent is its usage in the recent window
+// & Expected its usage in a window of the same length according to the baseline
+type TrendingEmote struct {
+	EmoteID  primitive.ObjectID         `bson:"emote_id" json:"emote_id"`
+	Name     string                     `bson:"name" json:"name"`
+	Flags    model.ActiveEmoteFlagModel `bson:"flags" json:"flags"`
+	URL      string                     `bson:"url" json:"url"`
+	Recent   int                        `bson:"recent" json:"recent"`
+	Expected float64                    `bson:"expected" json:"expected"`
+	Score    float64                    `bson:"score" json:"score"`
+}
+
+// ProcessedMessage is a message whose emotes were counted, used to skip redelivered messages
+type ProcessedMessage struct {
+	Key       string    `bson:"_id"`
+	CreatedAt time.Time `bson:"created_at"`
+}
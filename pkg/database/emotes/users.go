@@ -0,0 +1,162 @@
+package emotes
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/seventv/7tv-bot/pkg/types"
+)
+
+// UserEmote is the count of an emote used by a single chatter, ChannelID is empty if it's counted across all channels
+type UserEmote struct {
+	UserID    string
+	ChannelID string
+	Emote     types.CountedEmote
+}
+
+// IncrementUserEmotes increments the counts of the emotes per chatter in a single unordered bulk write
+func IncrementUserEmotes(ctx context.Context, emotes []UserEmote) error {
+	if len(emotes) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	models := make([]mongo.WriteModel, 0, len(emotes))
+	for _, emote := range emotes {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{
+				{"user_id", emote.UserID},
+				{"channel_id", emote.ChannelID},
+				{"emote_id", emote.Emote.Emote.EmoteID},
+			}).
+			SetUpdate(bson.M{
+				"$setOnInsert": bson.M{
+					"created_at": now,
+				},
+				"$set": bson.M{
+					"name":       emote.Emote.Emote.Name,
+					"flags":      emote.Emote.Emote.Flags,
+					"url":        emote.Emote.Emote.URL,
+					"updated_at": now,
+				},
+				"$inc": bson.M{"count": emote.Emote.Count},
+			}).
+			SetUpsert(true))
+	}
+
+	_, err := collections.UserStats.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// TopUserEmotes returns the emotes the chatter used most, most used first.
+// The counts are summed across all channels if channelID is empty.
+func TopUserEmotes(ctx context.Context, userID, channelID string, limit int64) ([]UserEmoteCount, error) {
+	match := bson.D{{"user_id", userID}}
+	if channelID != "" {
+		match = append(match, bson.E{"channel_id", channelID})
+	}
+
+	cursor, err := collections.UserStats.Aggregate(ctx, mongo.Pipeline{
+		{{"$match", match}},
+		{{"$sort", bson.D{{"updated_at", -1}}}},
+		{{"$group", bson.D{
+			{"_id", "$emote_id"},
+			// the emote data of the most recent use
+			{"name", bson.D{{"$first", "$name"}}},
+			{"flags", bson.D{{"$first", "$flags"}}},
+			{"url", bson.D{{"$first", "$url"}}},
+			{"created_at", bson.D{{"$min", "$created_at"}}},
+			{"updated_at", bson.D{{"$max", "$updated_at"}}},
+			{"count", bson.D{{"$sum", "$count"}}},
+		}}},
+		{{"$sort", bson.D{{"count", -1}, {"_id", 1}}}},
+		{{"$limit", limit}},
+		{{"$addFields", bson.D{
+			{"emote_id", "$_id"},
+			{"user_id", userID},
+			{"channel_id", channelID},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []UserEmoteCount{}
+	err = cursor.All(ctx, &result)
+	return result, err
+}
+
+// DeleteUserEmotes deletes all emote counts of the chatter, returns the amount of deleted counts
+func DeleteUserEmotes(ctx context.Context, userID string) (int64, error) {
+	res, err := collections.UserStats.DeleteMany(ctx, bson.D{{"user_id", userID}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// OptOut stops counting the emotes of the chatter & deletes the counts we already have.
+// A flush that read the opt outs before they were committed doesn't conflict with the deletion when it inserts new counts,
+// so the counts are deleted again once the opt out is committed. The deletion is eventually consistent:
+// a flush that was still in flight after that can leave counts behind, deleting the user's stats again removes them.
+func OptOut(ctx context.Context, userID string) (int64, error) {
+	var deleted int64
+	err := WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := collections.OptOuts.UpdateOne(
+			ctx,
+			bson.D{{"_id", userID}},
+			bson.M{"$setOnInsert": OptOutUser{UserID: userID, CreatedAt: time.Now().UTC()}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+		deleted, err = DeleteUserEmotes(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return deleted, err
+	}
+
+	// every flush starting from now sees the opt out, this catches the ones that committed in the meantime
+	late, err := DeleteUserEmotes(ctx, userID)
+	return deleted + late, err
+}
+
+// OptIn removes the chatter from the opt out list, their emotes are counted again from now on
+func OptIn(ctx context.Context, userID string) error {
+	_, err := collections.OptOuts.DeleteOne(ctx, bson.D{{"_id", userID}})
+	return err
+}
+
+// OptedOut returns which of the chatters opted out of having their emotes counted
+func OptedOut(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	optedOut := make(map[string]bool)
+	if len(userIDs) == 0 {
+		return optedOut, nil
+	}
+
+	cursor, err := collections.OptOuts.Find(
+		ctx,
+		bson.D{{"_id", bson.D{{"$in", userIDs}}}},
+		options.Find().SetProjection(bson.D{{"_id", 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		doc := OptOutUser{}
+		err = cursor.Decode(&doc)
+		if err != nil {
+			return nil, err
+		}
+		optedOut[doc.UserID] = true
+	}
+	return optedOut, cursor.Err()
+}
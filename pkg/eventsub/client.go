@@ -0,0 +1,249 @@
+package eventsub
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/util"
+)
+
+var (
+	// URL is the address of the Twitch EventSub WebSocket server
+	URL = "wss://eventsub.wss.twitch.tv/ws"
+	// KeepaliveGrace is added to the keepalive timeout of the session, to account for network latency
+	KeepaliveGrace = 5 * time.Second
+)
+
+// Client handles a single EventSub WebSocket connection, including keepalive & reconnect messages.
+// Subscriptions have to be created through the Helix API, using the session ID passed to OnWelcome.
+type Client struct {
+	url string
+
+	mx      sync.Mutex
+	session Session
+
+	disconnect util.Closer
+
+	onWelcome      func(session Session)
+	onNotification func(notification *NotificationPayload)
+	onRevocation   func(subscription Subscription)
+}
+
+// frame is a message read from one of the connections, after a session_reconnect there can briefly be two
+type frame struct {
+	conn *websocket.Conn
+	msg  *Message
+	err  error
+}
+
+// New returns a new client connecting to the Twitch EventSub WebSocket server
+func New() *Client {
+	c := &Client{url: URL}
+	c.disconnect.Reset()
+	return c
+}
+
+// WithURL changes the address the client connects to, useful for testing against a local server
+func (c *Client) WithURL(url string) *Client {
+	c.url = url
+	return c
+}
+
+// OnWelcome sets a callback, executed when a new session is created.
+// Subscriptions must be (re)created for the new session in this callback.
+// It is not called for the welcome message after a session_reconnect, since subscriptions carry over.
+func (c *Client) OnWelcome(cb func(session Session)) {
+	c.onWelcome = cb
+}
+
+// OnNotification sets a callback, executed on all notifications
+func (c *Client) OnNotification(cb func(notification *NotificationPayload)) {
+	c.onNotification = cb
+}
+
+// OnRevocation sets a callback, executed when Twitch revokes a subscription
+func (c *Client) OnRevocation(cb func(subscription Subscription)) {
+	c.onRevocation = cb
+}
+
+// SessionID returns the ID of the current session, empty if not connected
+func (c *Client) SessionID() string {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.session.ID
+}
+
+// Disconnect closes the connection, Connect will return ErrClientDisconnected
+func (c *Client) Disconnect() {
+	c.disconnect.Close()
+}
+
+// Connect connects to the EventSub WebSocket server, and blocks until the connection is closed.
+// session_reconnect messages are handled without returning.
+func (c *Client) Connect(ctx context.Context) error {
+	c.disconnect.Reset()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	frames := make(chan frame)
+
+	current, err := dial(ctx, c.url, frames)
+	if err != nil {
+		return err
+	}
+	// pending is the connection we're moving to after a session_reconnect
+	var pending *websocket.Conn
+	defer func() {
+		current.Close()
+		if pending != nil {
+			pending.Close()
+		}
+		c.mx.Lock()
+		c.session = Session{}
+		c.mx.Unlock()
+	}()
+
+	// Twitch sends the welcome message within 10 seconds
+	keepalive := time.NewTimer(10*time.Second + KeepaliveGrace)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.disconnect.C:
+			return ErrClientDisconnected
+		case <-keepalive.C:
+			return ErrKeepaliveTimeout
+		case f := <-frames:
+			if f.err != nil {
+				// the old connection gets closed by the server after a reconnect, so we only care about the current one
+				if f.conn == current {
+					return f.err
+				}
+				continue
+			}
+
+			session, err := c.handleMessage(f.msg, f.conn == pending)
+			if err != nil {
+				zap.S().Errorw("failed to handle eventsub message", "error", err, "type", f.msg.Metadata.MessageType)
+				continue
+			}
+
+			switch f.msg.Metadata.MessageType {
+			case SessionWelcome:
+				if f.conn == pending {
+					current.Close()
+					current, pending = pending, nil
+				}
+			case SessionReconnect:
+				if pending != nil {
+					pending.Close()
+				}
+				pending, err = dial(ctx, session.ReconnectURL, frames)
+				if err != nil {
+					return err
+				}
+			}
+
+			if f.conn == current {
+				c.mx.Lock()
+				timeout := time.Duration(c.session.KeepaliveTimeoutSeconds) * time.Second
+				c.mx.Unlock()
+				keepalive.Reset(timeout + KeepaliveGrace)
+			}
+		}
+	}
+}
+
+// Run connects to the EventSub WebSocket server, and reconnects after the given delay whenever the connection closes,
+// until ctx is cancelled
+func (c *Client) Run(ctx context.Context, delay time.Duration) error {
+	for {
+		err := c.Connect(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		zap.S().Errorw("eventsub connection closed, reconnecting", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// handleMessage runs the callbacks for the message, returns the session if the message contains one
+func (c *Client) handleMessage(msg *Message, isReconnect bool) (*Session, error) {
+	switch msg.Metadata.MessageType {
+	case SessionWelcome, SessionReconnect:
+		payload := &sessionPayload{}
+		err := json.Unmarshal(msg.Payload, payload)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Metadata.MessageType == SessionReconnect {
+			return &payload.Session, nil
+		}
+
+		c.mx.Lock()
+		c.session = payload.Session
+		c.mx.Unlock()
+
+		if !isReconnect && c.onWelcome != nil {
+			c.onWelcome(payload.Session)
+		}
+		return &payload.Session, nil
+	case Notification:
+		payload := &NotificationPayload{Metadata: msg.Metadata}
+		err := json.Unmarshal(msg.Payload, payload)
+		if err != nil {
+			return nil, err
+		}
+		if c.onNotification != nil {
+			c.onNotification(payload)
+		}
+	case Revocation:
+		payload := &revocationPayload{}
+		err := json.Unmarshal(msg.Payload, payload)
+		if err != nil {
+			return nil, err
+		}
+		if c.onRevocation != nil {
+			c.onRevocation(payload.Subscription)
+		}
+	}
+	return nil, nil
+}
+
+// dial connects to the given url, and starts a reader sending all messages to frames until ctx is cancelled
+func dial(ctx context.Context, url string, frames chan<- frame) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			msg := &Message{}
+			err := conn.ReadJSON(msg)
+			select {
+			case frames <- frame{conn: conn, msg: msg, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return conn, nil
+}
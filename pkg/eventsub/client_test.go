@@ -0,0 +1,130 @@
+package eventsub
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testServer is a minimal EventSub WebSocket server, sending the given messages after the welcome message
+type testServer struct {
+	*httptest.Server
+	upgrader websocket.Upgrader
+	messages func(url string) []any
+}
+
+func newTestServer(messages func(url string) []any) *testServer {
+	s := &testServer{messages: messages}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *testServer) wsURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func (s *testServer) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sessionID := "session"
+	if r.URL.Query().Has("reconnect") {
+		sessionID = "reconnected"
+	}
+	conn.WriteJSON(testMessage(SessionWelcome, sessionPayload{Session: Session{ID: sessionID, KeepaliveTimeoutSeconds: 10}}))
+
+	// only send the messages on the first connection
+	if sessionID == "session" {
+		for _, msg := range s.messages(s.wsURL()) {
+			conn.WriteJSON(msg)
+		}
+	}
+
+	// wait for the client to close the connection
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func testMessage(messageType string, payload any) Message {
+	data, _ := json.Marshal(payload)
+	return Message{
+		Metadata: Metadata{
+			MessageID:        messageType,
+			MessageType:      messageType,
+			MessageTimestamp: time.Now(),
+		},
+		Payload: data,
+	}
+}
+
+func TestClient_Connect(t *testing.T) {
+	server := newTestServer(func(url string) []any {
+		return []any{
+			testMessage(Notification, NotificationPayload{
+				Subscription: Subscription{Type: StreamOnline},
+				Event:        json.RawMessage(`{"broadcaster_user_login":"forsen"}`),
+			}),
+			testMessage(SessionReconnect, sessionPayload{Session: Session{ReconnectURL: url + "?reconnect"}}),
+		}
+	})
+	defer server.Close()
+
+	welcomes := 0
+	notifications := make(chan *NotificationPayload, 1)
+
+	client := New().WithURL(server.wsURL())
+	client.OnWelcome(func(session Session) {
+		welcomes++
+	})
+	client.OnNotification(func(notification *NotificationPayload) {
+		notifications <- notification
+	})
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- client.Connect(context.Background())
+	}()
+
+	select {
+	case notification := <-notifications:
+		event := &StreamOnlineEvent{}
+		err := json.Unmarshal(notification.Event, event)
+		if err != nil {
+			t.Fatalf("failed to decode event: %v", err)
+		}
+		if event.BroadcasterUserLogin != "forsen" {
+			t.Errorf("BroadcasterUserLogin = %v, want forsen", event.BroadcasterUserLogin)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification received")
+	}
+
+	// wait for the client to move to the new session
+	deadline := time.Now().Add(5 * time.Second)
+	for client.SessionID() != "reconnected" {
+		if time.Now().After(deadline) {
+			t.Fatalf("SessionID() = %v, want reconnected", client.SessionID())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if welcomes != 1 {
+		t.Errorf("OnWelcome called %v times, want 1", welcomes)
+	}
+
+	client.Disconnect()
+	if err := <-errChan; err != ErrClientDisconnected {
+		t.Errorf("Connect() error = %v, want %v", err, ErrClientDisconnected)
+	}
+}
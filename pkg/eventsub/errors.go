@@ -0,0 +1,12 @@
+package eventsub
+
+import "errors"
+
+var (
+	// ErrClientDisconnected is returned when the client has disconnected
+	ErrClientDisconnected = errors.New("client disconnected")
+	// ErrKeepaliveTimeout is returned when the server didn't send any message within the keepalive timeout
+	ErrKeepaliveTimeout = errors.New("keepalive timeout")
+	// ErrSubscriptionLimit is returned when the session has no subscriptions left
+	ErrSubscriptionLimit = errors.New("eventsub subscription limit reached")
+)
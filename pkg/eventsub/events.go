@@ -0,0 +1,78 @@
+package eventsub
+
+import "time"
+
+// StreamOnlineEvent is sent for the stream.online subscription type
+type StreamOnlineEvent struct {
+	ID                   string    `json:"id"`
+	BroadcasterUserID    string    `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string    `json:"broadcaster_user_login"`
+	BroadcasterUserName  string    `json:"broadcaster_user_name"`
+	Type                 string    `json:"type"`
+	StartedAt            time.Time `json:"started_at"`
+}
+
+// StreamOfflineEvent is sent for the stream.offline subscription type
+type StreamOfflineEvent struct {
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+}
+
+// ChatMessageEvent is sent for the channel.chat.message subscription type
+type ChatMessageEvent struct {
+	BroadcasterUserID    string `json:"broadcaster_user_id"`
+	BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	BroadcasterUserName  string `json:"broadcaster_user_name"`
+	ChatterUserID        string `json:"chatter_user_id"`
+	ChatterUserLogin     string `json:"chatter_user_login"`
+	ChatterUserName      string `json:"chatter_user_name"`
+	MessageID            string `json:"message_id"`
+	Message              struct {
+		Text      string            `json:"text"`
+		Fragments []MessageFragment `json:"fragments"`
+	} `json:"message"`
+	Color       string      `json:"color"`
+	Badges      []ChatBadge `json:"badges"`
+	MessageType string      `json:"message_type"`
+	Reply       *ChatReply  `json:"reply"`
+	Cheer       *struct {
+		Bits int `json:"bits"`
+	} `json:"cheer"`
+	ChannelPointsCustomRewardID string `json:"channel_points_custom_reward_id"`
+}
+
+// MessageFragment is part of a chat message, Type is one of "text", "emote", "cheermote" or "mention"
+type MessageFragment struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emote *struct {
+		ID         string   `json:"id"`
+		EmoteSetID string   `json:"emote_set_id"`
+		OwnerID    string   `json:"owner_id"`
+		Format     []string `json:"format"`
+	} `json:"emote"`
+	Mention *struct {
+		UserID    string `json:"user_id"`
+		UserName  string `json:"user_name"`
+		UserLogin string `json:"user_login"`
+	} `json:"mention"`
+}
+
+type ChatBadge struct {
+	SetID string `json:"set_id"`
+	ID    string `json:"id"`
+	Info  string `json:"info"`
+}
+
+type ChatReply struct {
+	ParentMessageID   string `json:"parent_message_id"`
+	ParentMessageBody string `json:"parent_message_body"`
+	ParentUserID      string `json:"parent_user_id"`
+	ParentUserName    string `json:"parent_user_name"`
+	ParentUserLogin   string `json:"parent_user_login"`
+	ThreadMessageID   string `json:"thread_message_id"`
+	ThreadUserID      string `json:"thread_user_id"`
+	ThreadUserName    string `json:"thread_user_name"`
+	ThreadUserLogin   string `json:"thread_user_login"`
+}
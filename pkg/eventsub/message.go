@@ -0,0 +1,79 @@
+package eventsub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Message types sent by the EventSub WebSocket server
+const (
+	// SessionWelcome is the first message sent after connecting, contains the session ID needed to create subscriptions
+	SessionWelcome = "session_welcome"
+	// SessionKeepalive is sent when no other message has been sent within the keepalive timeout
+	SessionKeepalive = "session_keepalive"
+	// SessionReconnect means the server is about to disconnect the client, the client must connect to the given reconnect URL
+	SessionReconnect = "session_reconnect"
+	// Notification contains an event for one of the subscriptions
+	Notification = "notification"
+	// Revocation means the subscription has been revoked by Twitch, and will no longer send notifications
+	Revocation = "revocation"
+)
+
+// Subscription types used by the bot
+const (
+	StreamOnline       = "stream.online"
+	StreamOffline      = "stream.offline"
+	ChannelChatMessage = "channel.chat.message"
+)
+
+// Message is a single message received from the EventSub WebSocket server
+type Message struct {
+	Metadata Metadata        `json:"metadata"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+type Metadata struct {
+	MessageID           string    `json:"message_id"`
+	MessageType         string    `json:"message_type"`
+	MessageTimestamp    time.Time `json:"message_timestamp"`
+	SubscriptionType    string    `json:"subscription_type,omitempty"`
+	SubscriptionVersion string    `json:"subscription_version,omitempty"`
+}
+
+// Session is the WebSocket session, its ID is needed to create subscriptions
+type Session struct {
+	ID                      string    `json:"id"`
+	Status                  string    `json:"status"`
+	KeepaliveTimeoutSeconds int       `json:"keepalive_timeout_seconds"`
+	ReconnectURL            string    `json:"reconnect_url"`
+	ConnectedAt             time.Time `json:"connected_at"`
+}
+
+type Subscription struct {
+	ID        string            `json:"id"`
+	Status    string            `json:"status"`
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Cost      int               `json:"cost"`
+	Condition map[string]string `json:"condition"`
+	Transport struct {
+		Method    string `json:"method"`
+		SessionID string `json:"session_id"`
+	} `json:"transport"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotificationPayload contains the subscription the event was sent for, the event itself can be decoded using the Subscription Type
+type NotificationPayload struct {
+	Metadata     Metadata        `json:"-"`
+	Subscription Subscription    `json:"subscription"`
+	Event        json.RawMessage `json:"event"`
+}
+
+type sessionPayload struct {
+	Session Session `json:"session"`
+}
+
+type revocationPayload struct {
+	Subscription Subscription `json:"subscription"`
+}
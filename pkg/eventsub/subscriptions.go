@@ -0,0 +1,178 @@
+package eventsub
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/helix"
+)
+
+var (
+	// MaxSubscriptions is the amount of enabled subscriptions Twitch allows on a single WebSocket session
+	MaxSubscriptions = 300
+)
+
+// Subscriber creates & deletes subscriptions, implemented by helix.Client.
+// WebSocket subscriptions require the client to use a user access token.
+type Subscriber interface {
+	CreateEventSubSubscription(ctx context.Context, subType, version string, condition map[string]string, transport helix.EventSubTransport) (*helix.EventSubSubscription, error)
+	DeleteEventSubSubscription(ctx context.Context, id string) error
+}
+
+// Topic is a subscription type & version, with the condition to subscribe to
+type Topic struct {
+	Type      string
+	Version   string
+	Condition map[string]string
+}
+
+// Subscriptions keeps track of subscriptions grouped by key (usually a channel ID),
+// and recreates them whenever the client gets a new session
+type Subscriptions struct {
+	client     *Client
+	subscriber Subscriber
+
+	mx      sync.Mutex
+	entries map[string]*subscriptionEntry
+	count   int
+
+	onResubscribe func(keys []string)
+}
+
+type subscriptionEntry struct {
+	topics []Topic
+	// ids of the subscriptions created for the current session
+	ids []string
+}
+
+// NewSubscriptions returns a new subscription manager, it takes over the OnWelcome callback of the client
+func NewSubscriptions(client *Client, subscriber Subscriber) *Subscriptions {
+	s := &Subscriptions{
+		client:     client,
+		subscriber: subscriber,
+		entries:    make(map[string]*subscriptionEntry),
+	}
+	client.OnWelcome(func(session Session) {
+		// don't block the reader while resubscribing
+		go s.resubscribe(context.Background(), session.ID)
+	})
+	return s
+}
+
+// OnResubscribe sets a callback, executed after all subscriptions have been recreated for a new session,
+// with the keys of all entries. Events could've been missed while reconnecting.
+func (s *Subscriptions) OnResubscribe(cb func(keys []string)) {
+	s.onResubscribe = cb
+}
+
+// Add subscribes to the given topics, grouped under key.
+// If the client has no session yet, the subscriptions are created once it has one.
+// Returns ErrSubscriptionLimit if the session can't take any more subscriptions.
+func (s *Subscriptions) Add(ctx context.Context, key string, topics ...Topic) error {
+	s.mx.Lock()
+	if _, ok := s.entries[key]; ok {
+		s.mx.Unlock()
+		return nil
+	}
+	if s.count+len(topics) > MaxSubscriptions {
+		s.mx.Unlock()
+		return ErrSubscriptionLimit
+	}
+	entry := &subscriptionEntry{topics: topics}
+	s.entries[key] = entry
+	s.count += len(topics)
+	s.mx.Unlock()
+
+	sessionID := s.client.SessionID()
+	if sessionID == "" {
+		return nil
+	}
+
+	err := s.subscribe(ctx, sessionID, key, entry)
+	if err != nil {
+		s.remove(key)
+		return err
+	}
+	return nil
+}
+
+// Remove deletes the subscriptions grouped under key
+func (s *Subscriptions) Remove(ctx context.Context, key string) error {
+	entry := s.remove(key)
+	if entry == nil {
+		return nil
+	}
+	for _, id := range entry.ids {
+		err := s.subscriber.DeleteEventSubSubscription(ctx, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Has returns true if there are subscriptions grouped under key
+func (s *Subscriptions) Has(key string) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	_, ok := s.entries[key]
+	return ok
+}
+
+func (s *Subscriptions) remove(key string) *subscriptionEntry {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	delete(s.entries, key)
+	s.count -= len(entry.topics)
+	return entry
+}
+
+func (s *Subscriptions) subscribe(ctx context.Context, sessionID, key string, entry *subscriptionEntry) error {
+	transport := helix.EventSubTransport{Method: "websocket", SessionID: sessionID}
+
+	var ids []string
+	for _, topic := range entry.topics {
+		subscription, err := s.subscriber.CreateEventSubSubscription(ctx, topic.Type, topic.Version, topic.Condition, transport)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, subscription.ID)
+	}
+
+	s.mx.Lock()
+	// only store the subscriptions if the entry hasn't been removed in the meantime
+	if s.entries[key] == entry {
+		entry.ids = ids
+	}
+	s.mx.Unlock()
+	return nil
+}
+
+// resubscribe creates the subscriptions of all entries for the new session
+func (s *Subscriptions) resubscribe(ctx context.Context, sessionID string) {
+	s.mx.Lock()
+	keys := make([]string, 0, len(s.entries))
+	entries := make([]*subscriptionEntry, 0, len(s.entries))
+	for key, entry := range s.entries {
+		keys = append(keys, key)
+		entries = append(entries, entry)
+	}
+	s.mx.Unlock()
+
+	for i, key := range keys {
+		err := s.subscribe(ctx, sessionID, key, entries[i])
+		if err != nil {
+			zap.S().Errorw("failed to subscribe to eventsub", "error", err, "key", key)
+		}
+	}
+
+	if s.onResubscribe != nil {
+		s.onResubscribe(keys)
+	}
+}
@@ -0,0 +1,215 @@
+package helix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// BaseURL is the default URL of the Helix API, can be overridden per client with WithBaseURL
+	BaseURL = "https://api.twitch.tv/helix"
+	// MaxBatchSize is the maximum amount of IDs or logins the Helix API accepts in a single request
+	MaxBatchSize = 100
+	// MaxRetries is the amount of times a request is retried after being rate limited
+	MaxRetries = 3
+	// RetryBackoff is the minimum wait before retrying a rate limited request, doubled on every retry.
+	// Helix doesn't always send the rate limit headers, without it we'd retry right away.
+	RetryBackoff = time.Second
+)
+
+// Client is a minimal Helix API client, authenticated with an app access token
+type Client struct {
+	clientID string
+	token    string
+	baseURL  string
+
+	httpClient *http.Client
+
+	// mx guards the token & rate limit state, since the client is shared between goroutines
+	mx sync.Mutex
+	// remaining is the amount of requests left in the current rate limit window, -1 means unknown
+	remaining int
+	reset     time.Time
+}
+
+// New returns a new Helix client, using the client ID & app access token for authentication
+func New(clientID, token string) *Client {
+	return &Client{
+		clientID:   clientID,
+		token:      token,
+		baseURL:    BaseURL,
+		httpClient: http.DefaultClient,
+		remaining:  -1,
+	}
+}
+
+// WithBaseURL changes the URL the client sends requests to, useful for testing against a local mock
+func (c *Client) WithBaseURL(baseURL string) *Client {
+	c.baseURL = baseURL
+	return c
+}
+
+// WithHTTPClient changes the underlying http client
+func (c *Client) WithHTTPClient(client *http.Client) *Client {
+	c.httpClient = client
+	return c
+}
+
+// UpdateToken changes the app access token used for future requests
+func (c *Client) UpdateToken(token string) {
+	c.mx.Lock()
+	c.token = token
+	c.mx.Unlock()
+}
+
+// get sends a GET request to the given Helix endpoint, and decodes the "data" field of the response into result
+func (c *Client) get(ctx context.Context, endpoint string, query url.Values, result any) error {
+	return c.request(ctx, http.MethodGet, endpoint, query, nil, result)
+}
+
+// request sends a request to the given Helix endpoint, retrying when rate limited.
+// The body is encoded as JSON if not nil, the "data" field of the response is decoded into result if not nil.
+func (c *Client) request(ctx context.Context, method, endpoint string, query url.Values, body, result any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	backoff := RetryBackoff
+	for i := 0; i <= MaxRetries; i++ {
+		err := c.waitForRateLimit(ctx)
+		if err != nil {
+			return err
+		}
+
+		res, err := c.do(ctx, method, endpoint, query, data)
+		if err != nil {
+			return err
+		}
+
+		c.updateRateLimit(res.Header)
+
+		switch res.StatusCode {
+		case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+			defer res.Body.Close()
+			if result == nil {
+				return nil
+			}
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(body, &response{Data: result})
+		case http.StatusTooManyRequests:
+			res.Body.Close()
+			zap.S().Warnw("rate limited by helix", "endpoint", endpoint, "retry", i+1, "backoff", backoff)
+			if i == MaxRetries {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		case http.StatusUnauthorized:
+			res.Body.Close()
+			return ErrUnauthorized
+		default:
+			res.Body.Close()
+			return fmt.Errorf("%w: %v", ErrUnexpectedStatus, res.StatusCode)
+		}
+	}
+	return ErrRateLimited
+}
+
+func (c *Client) do(ctx context.Context, method, endpoint string, query url.Values, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+endpoint+"?"+query.Encode(), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mx.Lock()
+	req.Header.Set("Client-Id", c.clientID)
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	c.mx.Unlock()
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// waitForRateLimit blocks until the rate limit window resets, if we have no requests left in the current window
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	c.mx.Lock()
+	wait := time.Duration(0)
+	if c.remaining == 0 {
+		wait = time.Until(c.reset)
+	}
+	c.mx.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// updateRateLimit stores the rate limit state sent by Helix in the Ratelimit-Remaining & Ratelimit-Reset headers
+func (c *Client) updateRateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("Ratelimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	c.mx.Lock()
+	c.remaining = remaining
+	c.reset = time.Unix(reset, 0)
+	c.mx.Unlock()
+}
+
+type response struct {
+	Data any `json:"data"`
+}
+
+// batch splits the input in slices of at most MaxBatchSize
+func batch(in []string) [][]string {
+	var result [][]string
+	for len(in) > MaxBatchSize {
+		result = append(result, in[:MaxBatchSize])
+		in = in[MaxBatchSize:]
+	}
+	if len(in) > 0 {
+		result = append(result, in)
+	}
+	return result
+}
@@ -0,0 +1,99 @@
+package helix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_batch(t *testing.T) {
+	tests := []struct {
+		name string
+		in   int
+		want []int
+	}{
+		{
+			name: "Empty",
+			in:   0,
+			want: nil,
+		},
+		{
+			name: "Single",
+			in:   100,
+			want: []int{100},
+		},
+		{
+			name: "Multiple",
+			in:   250,
+			want: []int{100, 100, 50},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make([]string, tt.in)
+			got := batch(in)
+			if len(got) != len(tt.want) {
+				t.Fatalf("batch() returned %v batches, want %v", len(got), len(tt.want))
+			}
+			for i, b := range got {
+				if len(b) != tt.want[i] {
+					t.Errorf("batch() batch %v has length %v, want %v", i, len(b), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClient_GetUsersByID(t *testing.T) {
+	RetryBackoff = 10 * time.Millisecond
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Client-Id") != "client" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// rate limit the first request, so we know the client retries
+		if requests == 1 {
+			w.Header().Set("Ratelimit-Remaining", "0")
+			w.Header().Set("Ratelimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		var users []User
+		for _, id := range r.URL.Query()["id"] {
+			users = append(users, User{ID: id, Login: "user" + id})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": users})
+	}))
+	defer server.Close()
+
+	ids := make([]string, 150)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+
+	client := New("client", "token").WithBaseURL(server.URL)
+	users, err := client.GetUsersByID(context.Background(), ids...)
+	if err != nil {
+		t.Fatalf("GetUsersByID() error = %v", err)
+	}
+	if len(users) != len(ids) {
+		t.Errorf("GetUsersByID() returned %v users, want %v", len(users), len(ids))
+	}
+	// 1 rate limited request + 2 batches
+	if requests != 3 {
+		t.Errorf("GetUsersByID() sent %v requests, want 3", requests)
+	}
+
+	client.UpdateToken("expired")
+	_, err = client.GetUsersByID(context.Background(), "1")
+	if err != ErrUnauthorized {
+		t.Errorf("GetUsersByID() error = %v, want %v", err, ErrUnauthorized)
+	}
+}
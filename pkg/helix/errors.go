@@ -0,0 +1,12 @@
+package helix
+
+import "errors"
+
+var (
+	// ErrUnexpectedStatus is returned when the Helix API responds with a status code we don't handle
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+	// ErrUnauthorized is returned when the Helix API rejects the token, usually means it expired and needs to be updated
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrRateLimited is returned when we're still being rate limited after retrying
+	ErrRateLimited = errors.New("rate limited")
+)
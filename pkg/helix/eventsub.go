@@ -0,0 +1,60 @@
+package helix
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// EventSubTransport describes how EventSub notifications are delivered
+type EventSubTransport struct {
+	// Method is either "websocket" or "webhook"
+	Method    string `json:"method"`
+	SessionID string `json:"session_id,omitempty"`
+	Callback  string `json:"callback,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+}
+
+// EventSubSubscription is an EventSub subscription as returned by the Helix API
+type EventSubSubscription struct {
+	ID        string            `json:"id"`
+	Status    string            `json:"status"`
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Condition map[string]string `json:"condition"`
+	Transport EventSubTransport `json:"transport"`
+	CreatedAt time.Time         `json:"created_at"`
+	Cost      int               `json:"cost"`
+}
+
+type createEventSubRequest struct {
+	Type      string            `json:"type"`
+	Version   string            `json:"version"`
+	Condition map[string]string `json:"condition"`
+	Transport EventSubTransport `json:"transport"`
+}
+
+// CreateEventSubSubscription subscribes to an EventSub topic.
+// Keep in mind, subscriptions with the websocket transport require the client to use a user access token.
+func (c *Client) CreateEventSubSubscription(ctx context.Context, subType, version string, condition map[string]string, transport EventSubTransport) (*EventSubSubscription, error) {
+	var subscriptions []EventSubSubscription
+	err := c.request(ctx, http.MethodPost, "/eventsub/subscriptions", url.Values{}, createEventSubRequest{
+		Type:      subType,
+		Version:   version,
+		Condition: condition,
+		Transport: transport,
+	}, &subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, ErrUnexpectedStatus
+	}
+	return &subscriptions[0], nil
+}
+
+// DeleteEventSubSubscription removes an EventSub subscription by its ID
+func (c *Client) DeleteEventSubSubscription(ctx context.Context, id string) error {
+	return c.request(ctx, http.MethodDelete, "/eventsub/subscriptions", url.Values{"id": {id}}, nil, nil)
+}
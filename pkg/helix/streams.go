@@ -0,0 +1,41 @@
+package helix
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Stream is a live stream as returned by the Helix API
+type Stream struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	UserLogin   string    `json:"user_login"`
+	UserName    string    `json:"user_name"`
+	GameID      string    `json:"game_id"`
+	GameName    string    `json:"game_name"`
+	Type        string    `json:"type"`
+	Title       string    `json:"title"`
+	ViewerCount int       `json:"viewer_count"`
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// GetStreams returns the streams of the given user IDs that are currently live, requests are batched per MaxBatchSize IDs.
+// Channels that are offline are left out of the result.
+func (c *Client) GetStreams(ctx context.Context, userIDs ...string) ([]Stream, error) {
+	var result []Stream
+	for _, b := range batch(userIDs) {
+		query := url.Values{"user_id": b}
+		// a single page can contain all streams of the batch
+		query.Set("first", strconv.Itoa(MaxBatchSize))
+
+		var streams []Stream
+		err := c.get(ctx, "/streams", query, &streams)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, streams...)
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package helix
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// User is a Twitch user as returned by the Helix API
+type User struct {
+	ID              string    `json:"id"`
+	Login           string    `json:"login"`
+	DisplayName     string    `json:"display_name"`
+	Type            string    `json:"type"`
+	BroadcasterType string    `json:"broadcaster_type"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// GetUsersByID looks up users by their ID, requests are batched per MaxBatchSize IDs.
+// Users that don't exist (anymore) are left out of the result.
+func (c *Client) GetUsersByID(ctx context.Context, ids ...string) ([]User, error) {
+	return c.getUsers(ctx, "id", ids)
+}
+
+// GetUsersByLogin looks up users by their login name, requests are batched per MaxBatchSize logins.
+// Users that don't exist (anymore) are left out of the result.
+func (c *Client) GetUsersByLogin(ctx context.Context, logins ...string) ([]User, error) {
+	return c.getUsers(ctx, "login", logins)
+}
+
+func (c *Client) getUsers(ctx context.Context, key string, values []string) ([]User, error) {
+	var result []User
+	for _, b := range batch(values) {
+		query := url.Values{key: b}
+
+		var users []User
+		err := c.get(ctx, "/users", query, &users)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, users...)
+	}
+	return result, nil
+}
@@ -1,6 +1,8 @@
 package irc
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"strings"
 )
 
@@ -26,8 +28,41 @@ const (
 	Cap
 	// Notice is a message about a command issues by the client, indicating whether it succeeded or failed
 	Notice
+	// UserNotice is a notification about an event in the channel, like a sub, resub, raid or announcement. Requires CapCommands.
+	UserNotice
+	// ClearChat means all messages in the channel, or all messages of a single user were removed, e.g. by a ban or timeout. Requires CapCommands.
+	ClearChat
+	// ClearMessage means a single message was removed from the channel. Requires CapCommands.
+	ClearMessage
+	// RoomState contains the chat settings of a channel, e.g. emote-only or slow mode. Requires CapCommands.
+	RoomState
 )
 
+// commands maps the IRC command to its message type
+var commands = map[string]MessageType{
+	"PING":       Ping,
+	"RECONNECT":  Reconnect,
+	"JOIN":       Join,
+	"PART":       Part,
+	"PRIVMSG":    PrivMessage,
+	"CAP":        Cap,
+	"NOTICE":     Notice,
+	"USERNOTICE": UserNotice,
+	"CLEARCHAT":  ClearChat,
+	"CLEARMSG":   ClearMessage,
+	"ROOMSTATE":  RoomState,
+}
+
+// Command returns the IRC command of the message type, empty if the type is Unknown or Unset
+func (t MessageType) Command() string {
+	for command, messageType := range commands {
+		if messageType == t {
+			return command
+		}
+	}
+	return ""
+}
+
 type Message struct {
 	raw         string
 	messageType MessageType
@@ -62,6 +97,73 @@ func (m *Message) GetType() MessageType {
 	return m.messageType
 }
 
+// MessageID returns the id tag of the message, or a stable hash of the channel, sender, tmi-sent-ts & content for messages without one,
+// like CLEARCHAT or CLEARMSG. Every connection receiving the same message gets the same ID, so it can be used for deduplication.
+// Messages without either tag, like our own JOIN & PART or ROOMSTATE, return an empty ID & must not be deduplicated:
+// they're specific to the connection, and identical ones are sent again for every join.
+func (m *Message) MessageID() string {
+	if id := m.Tag("id"); id != "" {
+		return id
+	}
+	sentAt := m.Tag("tmi-sent-ts")
+	if sentAt == "" {
+		return ""
+	}
+
+	command, params := m.command()
+	sender := ""
+	if prefix := m.prefix(); prefix != "" {
+		sender, _, _ = strings.Cut(prefix, "!")
+	}
+	channel, content, _ := strings.Cut(params, " ")
+
+	// fields are separated by a byte that can't be part of an IRC message, so they can't shift into each other
+	key := strings.Join([]string{command, channel, sender, sentAt, content}, "\x00")
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Tag returns the value of an IRCv3 tag, empty if the message doesn't have it. Escaped values are returned as-is.
+func (m *Message) Tag(key string) string {
+	if !strings.HasPrefix(m.raw, "@") {
+		return ""
+	}
+	tags, _, _ := strings.Cut(m.raw[1:], " ")
+	for _, tag := range strings.Split(tags, ";") {
+		k, value, _ := strings.Cut(tag, "=")
+		if k == key {
+			return value
+		}
+	}
+	return ""
+}
+
+// prefix returns the message prefix without the leading colon, e.g. nick!user@host
+func (m *Message) prefix() string {
+	rest := m.raw
+	if strings.HasPrefix(rest, "@") {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return ""
+	}
+	prefix, _, _ := strings.Cut(rest[1:], " ")
+	return prefix
+}
+
+// command returns the IRC command & everything after it
+func (m *Message) command() (command, params string) {
+	rest := m.raw
+	if strings.HasPrefix(rest, "@") {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	if strings.HasPrefix(rest, ":") {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	command, params, _ = strings.Cut(rest, " ")
+	return command, params
+}
+
 func parseMessageType(rawMessage string) (MessageType, error) {
 	var i int
 	split := strings.Split(rawMessage, " ")
@@ -86,22 +188,9 @@ func parseMessageType(rawMessage string) (MessageType, error) {
 }
 
 func parseType(str string) MessageType {
-	switch str {
-	case "PING":
-		return Ping
-	case "RECONNECT":
-		return Reconnect
-	case "JOIN":
-		return Join
-	case "PART":
-		return Part
-	case "PRIVMSG":
-		return PrivMessage
-	case "CAP":
-		return Cap
-	case "NOTICE":
-		return Notice
-	default:
+	messageType, ok := commands[str]
+	if !ok {
 		return Unknown
 	}
+	return messageType
 }
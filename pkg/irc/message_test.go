@@ -39,6 +39,20 @@ func Test_message_GetType(t *testing.T) {
 			},
 			Reconnect,
 		},
+		{
+			"UserNotice",
+			fields{
+				raw: "@badge-info=;badges=staff/1,broadcaster/1,turbo/1;color=#008000;display-name=ronni;emotes=;id=db25007f-7a18-43eb-9379-80131e44d633;login=ronni;mod=0;msg-id=resub;msg-param-cumulative-months=6;room-id=12345678;subscriber=1;system-msg=ronni\\shas\\ssubscribed\\sfor\\s6\\smonths!;tmi-sent-ts=1507246572675;turbo=1;user-id=87654321;user-type=staff :tmi.twitch.tv USERNOTICE #dallas :Great stream -- keep it up!",
+			},
+			UserNotice,
+		},
+		{
+			"ClearChat",
+			fields{
+				raw: "@room-id=12345678;target-user-id=87654321;tmi-sent-ts=1642715756806 :tmi.twitch.tv CLEARCHAT #dallas :ronni",
+			},
+			ClearChat,
+		},
 		{
 			"Join",
 			fields{
@@ -122,3 +136,77 @@ func Test_parseMessage(t *testing.T) {
 		})
 	}
 }
+
+func Test_message_MessageID(t *testing.T) {
+	clearChat := "@room-id=12345678;target-user-id=87654321;tmi-sent-ts=1642715756806 :tmi.twitch.tv CLEARCHAT #dallas :ronni"
+	tests := []struct {
+		name string
+		raw  string
+		// same is another message that must get the same ID, different one that must not
+		same      string
+		different string
+		want      string
+		// empty is set for messages that must not be deduplicated
+		empty bool
+	}{
+		{
+			name: "id tag",
+			raw:  "@badges=;id=23ebb86b-f9fa-47b8-893c-708587661afc;tmi-sent-ts=1690815698066 :fossabot!fossabot@fossabot.tmi.twitch.tv PRIVMSG #sodapoppin :hi",
+			want: "23ebb86b-f9fa-47b8-893c-708587661afc",
+		},
+		{
+			name:      "hash, tags in different order",
+			raw:       clearChat,
+			same:      "@tmi-sent-ts=1642715756806;target-user-id=87654321;room-id=12345678 :tmi.twitch.tv CLEARCHAT #dallas :ronni",
+			different: "@room-id=12345678;target-user-id=87654321;tmi-sent-ts=1642715756806 :tmi.twitch.tv CLEARCHAT #dallas :forsen",
+		},
+		{
+			name:      "hash, different timestamp",
+			raw:       clearChat,
+			different: "@room-id=12345678;target-user-id=87654321;tmi-sent-ts=1642715756807 :tmi.twitch.tv CLEARCHAT #dallas :ronni",
+		},
+		{
+			name:  "no tags",
+			raw:   ":7tvbot!7tvbot@7tvbot.tmi.twitch.tv JOIN #dallas",
+			empty: true,
+		},
+		{
+			name:  "tags without timestamp",
+			raw:   "@emote-only=0;followers-only=-1;r9k=0;room-id=12345678;slow=0;subs-only=0 :tmi.twitch.tv ROOMSTATE #dallas",
+			empty: true,
+		},
+		{
+			name:  "partial message",
+			raw:   "@id",
+			empty: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Message{raw: tt.raw}
+			got := m.MessageID()
+			if tt.empty {
+				if got != "" {
+					t.Errorf("MessageID() = %v, want empty", got)
+				}
+				return
+			}
+			if got == "" {
+				t.Fatal("MessageID() is empty")
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("MessageID() = %v, want %v", got, tt.want)
+			}
+			if tt.same != "" {
+				if same := (&Message{raw: tt.same}).MessageID(); same != got {
+					t.Errorf("MessageID() = %v, want same ID as %v", same, got)
+				}
+			}
+			if tt.different != "" {
+				if different := (&Message{raw: tt.different}).MessageID(); different == got {
+					t.Errorf("MessageID() = %v, want different ID", different)
+				}
+			}
+		})
+	}
+}
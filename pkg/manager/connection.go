@@ -36,9 +36,9 @@ type connection struct {
 }
 
 // newConnection sets up a new connection with capacity as set in ConnectionCapacity
-func newConnection(user, oauth string) *connection {
+func newConnection(user, oauth string, capabilities []string) *connection {
 	return &connection{
-		client:      irc.New(user, oauth).WithCapabilities(irc.CapTags),
+		client:      irc.New(user, oauth).WithCapabilities(capabilities...),
 		lastMessage: time.Now(),
 		channels:    []*IRCChannel{},
 		channelsMx:  &sync.Mutex{},
@@ -12,6 +12,8 @@ import (
 // IRCManager manages multiple IRC connections & keeps track of their connected channels
 type IRCManager struct {
 	user, oauth string
+	// capabilities requested on every new connection
+	capabilities []string
 
 	connectionCounter uint
 
@@ -42,8 +44,9 @@ type IRCManager struct {
 // Requires you to set OnMessage, and listen to the OrphanedChannels channel, before you call Init
 func New(user, oauth string) *IRCManager {
 	return &IRCManager{
-		user:  user,
-		oauth: oauth,
+		user:         user,
+		oauth:        oauth,
+		capabilities: []string{irc.CapTags},
 
 		connections: make(map[uint]*connection),
 		channels:    make(map[string]*IRCChannel),
@@ -69,6 +72,12 @@ func (m *IRCManager) WithLimit(limiter RateLimiter) *IRCManager {
 	return m
 }
 
+// WithCapabilities changes the twitch-irc capabilities requested on new connections, defaults to irc.CapTags
+func (m *IRCManager) WithCapabilities(caps ...string) *IRCManager {
+	m.capabilities = caps
+	return m
+}
+
 // Init does some basic checks to make sure the IRCManager is ready to use.
 //
 // It also starts a goroutine for reading channels to keep the service running properly
@@ -149,6 +158,17 @@ func (m *IRCManager) Part(channelName string) error {
 	return nil
 }
 
+// Channels returns copies of all channels the manager is joined to, or is joining
+func (m *IRCManager) Channels() []IRCChannel {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	channels := make([]IRCChannel, 0, len(m.channels))
+	for _, channel := range m.channels {
+		channels = append(channels, *channel)
+	}
+	return channels
+}
+
 // OnMessage sets a callback, executed on all incoming IRC messages from every connection.
 // Must be set before you try to Join channels, not setting this will result in nil pointer!
 // The callback will be agnostic of which underlying connection it came from.
@@ -223,7 +243,7 @@ func (m *IRCManager) addNewConnection() uint {
 		m.connectionCounter++
 	}
 
-	con := newConnection(m.user, m.oauth)
+	con := newConnection(m.user, m.oauth, m.capabilities)
 	con.Parted = m.partedChannels
 	con.setOnMessage(m.onMessage)
 
@@ -0,0 +1,154 @@
+package message
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seventv/7tv-bot/pkg/irc"
+)
+
+// FromIRC converts a raw PRIVMSG with tags to a normalized message
+func FromIRC(raw string, receivedAt time.Time) (*Message, error) {
+	msg := &Message{
+		Version:    Version,
+		ReceivedAt: receivedAt,
+	}
+
+	rest := raw
+	tags := map[string]string{}
+	if strings.HasPrefix(rest, "@") {
+		var rawTags string
+		var ok bool
+		rawTags, rest, ok = strings.Cut(rest[1:], " ")
+		if !ok {
+			return nil, irc.ErrPartialMessage
+		}
+		tags = parseTags(rawTags)
+	}
+
+	if strings.HasPrefix(rest, ":") {
+		var prefix string
+		var ok bool
+		prefix, rest, ok = strings.Cut(rest[1:], " ")
+		if !ok {
+			return nil, irc.ErrPartialMessage
+		}
+		msg.Sender.Login, _, _ = strings.Cut(prefix, "!")
+	}
+
+	command, rest, ok := strings.Cut(rest, " ")
+	if !ok {
+		return nil, irc.ErrPartialMessage
+	}
+	if command != "PRIVMSG" {
+		return nil, ErrUnsupportedType
+	}
+	msg.Type = strings.ToLower(command)
+
+	channel, text, ok := strings.Cut(rest, " ")
+	if !ok {
+		return nil, irc.ErrPartialMessage
+	}
+	msg.Channel.Login = strings.TrimPrefix(channel, "#")
+	msg.Text = strings.TrimPrefix(text, ":")
+
+	msg.ID = tags["id"]
+	msg.Channel.ID = tags["room-id"]
+	msg.Sender.ID = tags["user-id"]
+	msg.Sender.DisplayName = tags["display-name"]
+	msg.Color = tags["color"]
+	msg.Badges = parseBadges(tags["badges"])
+	msg.Emotes = parseEmotes(tags["emotes"], msg.Text)
+
+	if ts, err := strconv.ParseInt(tags["tmi-sent-ts"], 10, 64); err == nil {
+		msg.SentAt = time.UnixMilli(ts)
+	}
+
+	if parentID, ok := tags["reply-parent-msg-id"]; ok {
+		msg.Reply = &Reply{
+			ParentMessageID: parentID,
+			ParentUser: User{
+				ID:          tags["reply-parent-user-id"],
+				Login:       tags["reply-parent-user-login"],
+				DisplayName: tags["reply-parent-display-name"],
+			},
+			ParentBody:      tags["reply-parent-msg-body"],
+			ThreadMessageID: tags["reply-thread-parent-msg-id"],
+		}
+	}
+
+	return msg, nil
+}
+
+var tagValueUnescaper = strings.NewReplacer(
+	"\\\\", "\\",
+	"\\:", ";",
+	"\\s", " ",
+	"\\r", "\r",
+	"\\n", "\n",
+)
+
+func parseTags(raw string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(raw, ";") {
+		key, value, _ := strings.Cut(tag, "=")
+		tags[key] = tagValueUnescaper.Replace(value)
+	}
+	return tags
+}
+
+// parseBadges parses the badges tag, e.g. "moderator/1,subscriber/3054"
+func parseBadges(raw string) []Badge {
+	if raw == "" {
+		return nil
+	}
+	var badges []Badge
+	for _, badge := range strings.Split(raw, ",") {
+		set, version, _ := strings.Cut(badge, "/")
+		badges = append(badges, Badge{Set: set, Version: version})
+	}
+	return badges
+}
+
+// parseEmotes parses the emotes tag, e.g. "25:0-4,12-16/1902:6-10", names are taken from the text
+func parseEmotes(raw, text string) []Emote {
+	if raw == "" {
+		return nil
+	}
+	runes := []rune(text)
+
+	var emotes []Emote
+	for _, emote := range strings.Split(raw, "/") {
+		id, positions, ok := strings.Cut(emote, ":")
+		if !ok {
+			continue
+		}
+		for _, position := range strings.Split(positions, ",") {
+			startStr, endStr, ok := strings.Cut(position, "-")
+			if !ok {
+				continue
+			}
+			start, err := strconv.Atoi(startStr)
+			if err != nil {
+				continue
+			}
+			end, err := strconv.Atoi(endStr)
+			if err != nil || start > end || end >= len(runes) {
+				continue
+			}
+			emotes = append(emotes, Emote{
+				ID:    id,
+				Name:  string(runes[start : end+1]),
+				Start: start,
+				End:   end,
+			})
+		}
+	}
+
+	sort.Slice(emotes, func(i, j int) bool {
+		return emotes[i].Start < emotes[j].Start
+	})
+	return emotes
+}
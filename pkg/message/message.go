@@ -0,0 +1,98 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Version of the message schema, incremented on breaking changes
+const Version = 1
+
+// Formats the message can be encoded in, used as the value of the Format header on NATS
+const (
+	FormatJSON  = "json"
+	FormatProto = "protobuf"
+)
+
+const (
+	// HeaderFormat is the NATS header containing the format of the message
+	HeaderFormat = "Format"
+	// HeaderVersion is the NATS header containing the schema version of the message
+	HeaderVersion = "Schema-Version"
+)
+
+var (
+	// ErrUnknownFormat is returned when encoding or decoding a format that isn't supported
+	ErrUnknownFormat = errors.New("unknown message format")
+	// ErrUnsupportedType is returned when converting an IRC message type that isn't supported
+	ErrUnsupportedType = errors.New("unsupported message type")
+)
+
+// Message is a normalized chat message, so consumers don't need to parse IRC
+type Message struct {
+	Version    int       `json:"version"`
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Channel    User      `json:"channel"`
+	Sender     User      `json:"sender"`
+	Badges     []Badge   `json:"badges,omitempty"`
+	Color      string    `json:"color,omitempty"`
+	Text       string    `json:"text"`
+	Emotes     []Emote   `json:"emotes,omitempty"`
+	Reply      *Reply    `json:"reply,omitempty"`
+	SentAt     time.Time `json:"sent_at"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+type User struct {
+	ID          string `json:"id"`
+	Login       string `json:"login"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+type Badge struct {
+	Set     string `json:"set"`
+	Version string `json:"version"`
+}
+
+// Emote is a Twitch emote in the message, Start & End are character positions in the text, End is inclusive
+type Emote struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type Reply struct {
+	ParentMessageID string `json:"parent_message_id"`
+	ParentUser      User   `json:"parent_user"`
+	ParentBody      string `json:"parent_body"`
+	ThreadMessageID string `json:"thread_message_id,omitempty"`
+}
+
+// Marshal encodes the message in the given format
+func Marshal(msg *Message, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return json.Marshal(msg)
+	case FormatProto:
+		return marshalProto(msg), nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// Unmarshal decodes a message encoded in the given format
+func Unmarshal(data []byte, format string) (*Message, error) {
+	switch format {
+	case FormatJSON:
+		msg := &Message{}
+		err := json.Unmarshal(data, msg)
+		return msg, err
+	case FormatProto:
+		return unmarshalProto(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
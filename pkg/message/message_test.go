@@ -0,0 +1,131 @@
+package message
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFromIRC(t *testing.T) {
+	received := time.UnixMilli(1690815698100)
+	type args struct {
+		raw string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Message
+		wantErr bool
+	}{
+		{
+			name: "privmsg+tags",
+			args: args{
+				raw: "@badge-info=subscriber/57;badges=moderator/1,subscriber/3054,partner/1;color=#1976D2;display-name=Fossabot;emotes=25:0-4,12-16/1902:6-10;first-msg=0;flags=;id=23ebb86b-f9fa-47b8-893c-708587661afc;mod=1;returning-chatter=0;room-id=26301881;subscriber=1;tmi-sent-ts=1690815698066;turbo=0;user-id=237719657;user-type=mod :fossabot!fossabot@fossabot.tmi.twitch.tv PRIVMSG #sodapoppin :Kappa Keepo Kappa",
+			},
+			want: &Message{
+				Version: Version,
+				ID:      "23ebb86b-f9fa-47b8-893c-708587661afc",
+				Type:    "privmsg",
+				Channel: User{ID: "26301881", Login: "sodapoppin"},
+				Sender:  User{ID: "237719657", Login: "fossabot", DisplayName: "Fossabot"},
+				Badges: []Badge{
+					{Set: "moderator", Version: "1"},
+					{Set: "subscriber", Version: "3054"},
+					{Set: "partner", Version: "1"},
+				},
+				Color: "#1976D2",
+				Text:  "Kappa Keepo Kappa",
+				Emotes: []Emote{
+					{ID: "25", Name: "Kappa", Start: 0, End: 4},
+					{ID: "1902", Name: "Keepo", Start: 6, End: 10},
+					{ID: "25", Name: "Kappa", Start: 12, End: 16},
+				},
+				SentAt:     time.UnixMilli(1690815698066),
+				ReceivedAt: received,
+			},
+		},
+		{
+			name: "reply",
+			args: args{
+				raw: "@display-name=Fossabot;id=1;reply-parent-display-name=Forsen;reply-parent-msg-body=hello\\sthere;reply-parent-msg-id=2;reply-parent-user-id=22484632;reply-parent-user-login=forsen;room-id=26301881;tmi-sent-ts=1690815698066;user-id=237719657 :fossabot!fossabot@fossabot.tmi.twitch.tv PRIVMSG #sodapoppin :@forsen hi",
+			},
+			want: &Message{
+				Version: Version,
+				ID:      "1",
+				Type:    "privmsg",
+				Channel: User{ID: "26301881", Login: "sodapoppin"},
+				Sender:  User{ID: "237719657", Login: "fossabot", DisplayName: "Fossabot"},
+				Text:    "@forsen hi",
+				Reply: &Reply{
+					ParentMessageID: "2",
+					ParentUser:      User{ID: "22484632", Login: "forsen", DisplayName: "Forsen"},
+					ParentBody:      "hello there",
+				},
+				SentAt:     time.UnixMilli(1690815698066),
+				ReceivedAt: received,
+			},
+		},
+		{
+			name: "notice",
+			args: args{
+				raw: ":tmi.twitch.tv NOTICE * :Login authentication failed",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromIRC(tt.args.raw, received)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromIRC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromIRC() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	msg := &Message{
+		Version: Version,
+		ID:      "23ebb86b-f9fa-47b8-893c-708587661afc",
+		Type:    "privmsg",
+		Channel: User{ID: "26301881", Login: "sodapoppin"},
+		Sender:  User{ID: "237719657", Login: "fossabot", DisplayName: "Fossabot"},
+		Badges:  []Badge{{Set: "moderator", Version: "1"}},
+		Color:   "#1976D2",
+		Text:    "Kappa ° Keepo",
+		Emotes: []Emote{
+			{ID: "25", Name: "Kappa", Start: 0, End: 4},
+			{ID: "1902", Name: "Keepo", Start: 8, End: 12},
+		},
+		Reply: &Reply{
+			ParentMessageID: "2",
+			ParentUser:      User{ID: "22484632", Login: "forsen"},
+		},
+		SentAt:     time.UnixMilli(1690815698066),
+		ReceivedAt: time.UnixMilli(1690815698100),
+	}
+
+	for _, format := range []string{FormatJSON, FormatProto} {
+		t.Run(format, func(t *testing.T) {
+			data, err := Marshal(msg, format)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			got, err := Unmarshal(data, format)
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			// compare timestamps separately, since the location isn't encoded
+			if !got.SentAt.Equal(msg.SentAt) || !got.ReceivedAt.Equal(msg.ReceivedAt) {
+				t.Errorf("timestamps = %v %v, want %v %v", got.SentAt, got.ReceivedAt, msg.SentAt, msg.ReceivedAt)
+			}
+			got.SentAt, got.ReceivedAt = msg.SentAt, msg.ReceivedAt
+			if !reflect.DeepEqual(got, msg) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package message
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// marshalProto encodes the message following message.proto
+func marshalProto(msg *Message) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(msg.Version))
+	b = appendString(b, 2, msg.ID)
+	b = appendString(b, 3, msg.Type)
+	b = appendBytes(b, 4, marshalUser(msg.Channel))
+	b = appendBytes(b, 5, marshalUser(msg.Sender))
+	for _, badge := range msg.Badges {
+		var sub []byte
+		sub = appendString(sub, 1, badge.Set)
+		sub = appendString(sub, 2, badge.Version)
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendBytes(b, sub)
+	}
+	b = appendString(b, 7, msg.Color)
+	b = appendString(b, 8, msg.Text)
+	for _, emote := range msg.Emotes {
+		var sub []byte
+		sub = appendString(sub, 1, emote.ID)
+		sub = appendString(sub, 2, emote.Name)
+		sub = appendVarint(sub, 3, uint64(emote.Start))
+		sub = appendVarint(sub, 4, uint64(emote.End))
+		b = protowire.AppendTag(b, 9, protowire.BytesType)
+		b = protowire.AppendBytes(b, sub)
+	}
+	if msg.Reply != nil {
+		var sub []byte
+		sub = appendString(sub, 1, msg.Reply.ParentMessageID)
+		sub = appendBytes(sub, 2, marshalUser(msg.Reply.ParentUser))
+		sub = appendString(sub, 3, msg.Reply.ParentBody)
+		sub = appendString(sub, 4, msg.Reply.ThreadMessageID)
+		// an empty reply still needs to be encoded, so it's not decoded as nil
+		b = protowire.AppendTag(b, 10, protowire.BytesType)
+		b = protowire.AppendBytes(b, sub)
+	}
+	b = appendVarint(b, 11, uint64(msg.SentAt.UnixMilli()))
+	b = appendVarint(b, 12, uint64(msg.ReceivedAt.UnixMilli()))
+	return b
+}
+
+func marshalUser(user User) []byte {
+	var b []byte
+	b = appendString(b, 1, user.ID)
+	b = appendString(b, 2, user.Login)
+	b = appendString(b, 3, user.DisplayName)
+	return b
+}
+
+// unmarshalProto decodes a message following message.proto, unknown fields are skipped
+func unmarshalProto(b []byte) (*Message, error) {
+	msg := &Message{}
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			msg.Version = int(v)
+			return n, nil
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &msg.ID)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &msg.Type)
+		case num == 4 && typ == protowire.BytesType:
+			return consumeUser(b, &msg.Channel)
+		case num == 5 && typ == protowire.BytesType:
+			return consumeUser(b, &msg.Sender)
+		case num == 6 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+			badge := Badge{}
+			err := consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					return consumeString(b, &badge.Set)
+				case num == 2 && typ == protowire.BytesType:
+					return consumeString(b, &badge.Version)
+				}
+				return protowire.ConsumeFieldValue(num, typ, b), nil
+			})
+			msg.Badges = append(msg.Badges, badge)
+			return n, err
+		case num == 7 && typ == protowire.BytesType:
+			return consumeString(b, &msg.Color)
+		case num == 8 && typ == protowire.BytesType:
+			return consumeString(b, &msg.Text)
+		case num == 9 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+			emote := Emote{}
+			err := consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					return consumeString(b, &emote.ID)
+				case num == 2 && typ == protowire.BytesType:
+					return consumeString(b, &emote.Name)
+				case num == 3 && typ == protowire.VarintType:
+					v, n := protowire.ConsumeVarint(b)
+					emote.Start = int(v)
+					return n, nil
+				case num == 4 && typ == protowire.VarintType:
+					v, n := protowire.ConsumeVarint(b)
+					emote.End = int(v)
+					return n, nil
+				}
+				return protowire.ConsumeFieldValue(num, typ, b), nil
+			})
+			msg.Emotes = append(msg.Emotes, emote)
+			return n, err
+		case num == 10 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, protowire.ParseError(n)
+			}
+			reply := &Reply{}
+			err := consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					return consumeString(b, &reply.ParentMessageID)
+				case num == 2 && typ == protowire.BytesType:
+					return consumeUser(b, &reply.ParentUser)
+				case num == 3 && typ == protowire.BytesType:
+					return consumeString(b, &reply.ParentBody)
+				case num == 4 && typ == protowire.BytesType:
+					return consumeString(b, &reply.ThreadMessageID)
+				}
+				return protowire.ConsumeFieldValue(num, typ, b), nil
+			})
+			msg.Reply = reply
+			return n, err
+		case num == 11 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			msg.SentAt = time.UnixMilli(int64(v))
+			return n, nil
+		case num == 12 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			msg.ReceivedAt = time.UnixMilli(int64(v))
+			return n, nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return msg, err
+}
+
+func consumeUser(b []byte, user *User) (int, error) {
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n, protowire.ParseError(n)
+	}
+	err := consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, &user.ID)
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, &user.Login)
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, &user.DisplayName)
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return n, err
+}
+
+// consumeFields runs cb for every field in b, cb must return the amount of bytes it consumed for the field value
+func consumeFields(b []byte, cb func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n, err := cb(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func consumeString(b []byte, s *string) (int, error) {
+	v, n := protowire.ConsumeString(b)
+	if n < 0 {
+		return n, protowire.ParseError(n)
+	}
+	*s = v
+	return n, nil
+}
+
+// appendString appends a string field, empty strings are left out like proto3 does
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendBytes appends an embedded message, empty messages are left out
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// appendVarint appends a varint field, zero values are left out like proto3 does
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
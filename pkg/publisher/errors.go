@@ -0,0 +1,10 @@
+package publisher
+
+import "errors"
+
+var (
+	// ErrSpillFull is returned when a message doesn't fit in the spill directory anymore
+	ErrSpillFull = errors.New("spill directory is full")
+	// ErrCorruptRecord is returned when a record in a segment file fails its checksum, usually a write torn by a crash
+	ErrCorruptRecord = errors.New("corrupt record in segment")
+)
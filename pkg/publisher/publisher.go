@@ -0,0 +1,271 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// JetStream is the part of nats.JetStreamContext used for publishing
+type JetStream interface {
+	PublishMsg(m *nats.Msg, opts ...nats.PubOpt) (*nats.PubAck, error)
+}
+
+// Publisher publishes messages to JetStream in the background, so callers don't block on NATS.
+// Messages are buffered in a bounded in-memory queue, when it's full or NATS is unavailable,
+// they're spilled to disk if a spill directory is set, and replayed in order once publishing succeeds again.
+// Replayed messages keep their original headers, so JetStream deduplicates them by their Nats-Msg-Id.
+type Publisher struct {
+	js      JetStream
+	queue   chan *nats.Msg
+	workers int
+
+	spillDir      string
+	spillMaxBytes int64
+	spill         *spill
+	// replayInterval is how often we check for spilled messages, and back off after a failed replay
+	replayInterval time.Duration
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+	// mx guards closed, so the queue isn't written to after it's closed
+	mx     sync.RWMutex
+	closed bool
+
+	published atomic.Uint64
+	spilled   atomic.Uint64
+	replayed  atomic.Uint64
+	dropped   atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// Stats are counters of everything that happened to messages since the publisher was created
+type Stats struct {
+	// Queued is the amount of messages currently in the in-memory queue
+	Queued int
+	// SpillBytes is the size of all spilled messages currently on disk
+	SpillBytes int64
+	Published  uint64
+	Spilled    uint64
+	Replayed   uint64
+	// Dropped messages were lost, because the queue was full and they couldn't be spilled, or their spilled record didn't decode
+	Dropped uint64
+	// Failed is the amount of failed publish attempts
+	Failed uint64
+}
+
+// New returns a new Publisher with a queue of 10000 messages & 4 workers, without spilling to disk
+func New(js JetStream) *Publisher {
+	return &Publisher{
+		js:             js,
+		queue:          make(chan *nats.Msg, 10000),
+		workers:        4,
+		replayInterval: time.Second,
+	}
+}
+
+// WithQueue changes the amount of messages buffered in memory
+func (p *Publisher) WithQueue(size int) *Publisher {
+	p.queue = make(chan *nats.Msg, size)
+	return p
+}
+
+// WithWorkers changes the amount of goroutines publishing from the queue
+func (p *Publisher) WithWorkers(workers int) *Publisher {
+	if workers > 0 {
+		p.workers = workers
+	}
+	return p
+}
+
+// WithSpill spills messages to segment files in dir when they can't be queued or published.
+// maxBytes limits the total size of the segments, 0 means unlimited.
+func (p *Publisher) WithSpill(dir string, maxBytes int64) *Publisher {
+	p.spillDir = dir
+	p.spillMaxBytes = maxBytes
+	return p
+}
+
+// Start opens the spill directory & starts the workers, messages spilled before a restart are replayed as well
+func (p *Publisher) Start() error {
+	if p.spillDir != "" {
+		s, err := openSpill(p.spillDir, p.spillMaxBytes)
+		if err != nil {
+			return err
+		}
+		p.spill = s
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	p.wg.Add(p.workers)
+	for i := 0; i < p.workers; i++ {
+		go p.work()
+	}
+
+	if p.spill != nil {
+		p.wg.Add(1)
+		go p.replayLoop(ctx)
+	}
+	return nil
+}
+
+// Publish queues the message without blocking.
+// If the queue is full, the message is spilled to disk, or dropped if spilling isn't enabled.
+// Messages published after Close are dropped.
+func (p *Publisher) Publish(msg *nats.Msg) {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+	if p.closed {
+		p.dropped.Add(1)
+		return
+	}
+	select {
+	case p.queue <- msg:
+	default:
+		p.spillOrDrop(msg)
+	}
+}
+
+// Close stops accepting messages, publishes what's left in the queue, and flushes the spill to disk
+func (p *Publisher) Close() {
+	p.mx.Lock()
+	if p.closed {
+		p.mx.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.mx.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+
+	if p.spill != nil {
+		err := p.spill.close()
+		if err != nil {
+			zap.S().Errorw("failed to close spill", "error", err)
+		}
+	}
+}
+
+// Stats returns the current counters of the publisher
+func (p *Publisher) Stats() Stats {
+	stats := Stats{
+		Queued:    len(p.queue),
+		Published: p.published.Load(),
+		Spilled:   p.spilled.Load(),
+		Replayed:  p.replayed.Load(),
+		Dropped:   p.dropped.Load(),
+		Failed:    p.failed.Load(),
+	}
+	if p.spill != nil {
+		stats.SpillBytes = p.spill.bytes()
+	}
+	return stats
+}
+
+func (p *Publisher) work() {
+	defer p.wg.Done()
+	for msg := range p.queue {
+		// keep the order of messages, nothing is published directly while older messages are waiting on disk
+		if p.spill != nil && p.spill.bytes() > 0 {
+			p.spillOrDrop(msg)
+			continue
+		}
+
+		_, err := p.js.PublishMsg(msg)
+		if err != nil {
+			p.failed.Add(1)
+			zap.S().Debugw("failed to publish message", "error", err, "subject", msg.Subject)
+			p.spillOrDrop(msg)
+			continue
+		}
+		p.published.Add(1)
+	}
+}
+
+func (p *Publisher) spillOrDrop(msg *nats.Msg) {
+	if p.spill == nil {
+		p.dropped.Add(1)
+		return
+	}
+	err := p.spill.append(msg)
+	if err != nil {
+		p.dropped.Add(1)
+		zap.S().Errorw("failed to spill message", "error", err, "subject", msg.Subject)
+		return
+	}
+	p.spilled.Add(1)
+}
+
+// replayLoop publishes spilled segments oldest first, until ctx is cancelled
+func (p *Publisher) replayLoop(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.replayInterval)
+	defer ticker.Stop()
+
+	// replaying a segment can be interrupted, in that case we continue from the last published record
+	var offset int64
+	var current uint64
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := p.spill.flush()
+		if err != nil {
+			zap.S().Errorw("failed to flush spill", "error", err)
+		}
+
+		for ctx.Err() == nil {
+			seq, ok := p.spill.oldest()
+			if !ok {
+				break
+			}
+			if seq != current {
+				current = seq
+				offset = 0
+			}
+
+			offset, err = p.spill.replay(seq, offset, p.republish, func(err error) {
+				p.dropped.Add(1)
+				zap.S().Errorw("dropping spilled message that can't be decoded", "error", err, "segment", seq)
+			})
+			if err != nil && !errors.Is(err, ErrCorruptRecord) {
+				zap.S().Debugw("failed to replay spilled messages", "error", err, "segment", seq)
+				break
+			}
+			if err != nil {
+				zap.S().Errorw("skipping rest of corrupt segment", "error", err, "segment", seq, "offset", offset)
+			}
+
+			err = p.spill.remove(seq)
+			if err != nil {
+				zap.S().Errorw("failed to remove replayed segment", "error", err, "segment", seq)
+				break
+			}
+		}
+	}
+}
+
+func (p *Publisher) republish(msg *nats.Msg) error {
+	_, err := p.js.PublishMsg(msg)
+	if err != nil {
+		p.failed.Add(1)
+		return err
+	}
+	p.replayed.Add(1)
+	return nil
+}
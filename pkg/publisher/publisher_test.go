@@ -0,0 +1,269 @@
+package publisher
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// fakeJetStream records published messages, and fails while down is set
+type fakeJetStream struct {
+	mx        sync.Mutex
+	down      bool
+	published []*nats.Msg
+}
+
+func (f *fakeJetStream) PublishMsg(m *nats.Msg, _ ...nats.PubOpt) (*nats.PubAck, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	if f.down {
+		return nil, errors.New("nats unavailable")
+	}
+	f.published = append(f.published, m)
+	return &nats.PubAck{}, nil
+}
+
+func (f *fakeJetStream) setDown(down bool) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.down = down
+}
+
+func (f *fakeJetStream) ids() []string {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	ids := make([]string, 0, len(f.published))
+	for _, msg := range f.published {
+		ids = append(ids, msg.Header.Get("Nats-Msg-Id"))
+	}
+	return ids
+}
+
+func testMsg(i int) *nats.Msg {
+	header := nats.Header{}
+	header.Add("Nats-Msg-Id", strconv.Itoa(i))
+	return &nats.Msg{
+		Subject: "irc.raw.twitch.privmsg.forsen",
+		Header:  header,
+		Data:    []byte("message " + strconv.Itoa(i)),
+	}
+}
+
+func Test_spill(t *testing.T) {
+	s, err := openSpill(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err = s.append(testMsg(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	seq, ok := s.oldest()
+	if !ok {
+		t.Fatal("oldest() found no segment")
+	}
+
+	var got []*nats.Msg
+	failAt := 1
+	publish := func(msg *nats.Msg) error {
+		if len(got) == failAt {
+			failAt = -1
+			return errors.New("nats unavailable")
+		}
+		got = append(got, msg)
+		return nil
+	}
+
+	// the first replay fails halfway, the second one has to continue where it left off
+	offset, err := s.replay(seq, 0, publish, nil)
+	if err == nil {
+		t.Fatal("replay() expected error")
+	}
+	_, err = s.replay(seq, offset, publish, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("replay() published %v messages, want 3", len(got))
+	}
+	for i, msg := range got {
+		want := testMsg(i)
+		if msg.Subject != want.Subject || string(msg.Data) != string(want.Data) || msg.Header.Get("Nats-Msg-Id") != strconv.Itoa(i) {
+			t.Errorf("replay() message %v = %+v, want %+v", i, msg, want)
+		}
+	}
+
+	err = s.remove(seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.bytes() != 0 {
+		t.Errorf("bytes() = %v after removing all segments, want 0", s.bytes())
+	}
+}
+
+func Test_spillTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	s, err := openSpill(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.append(testMsg(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// simulate a crash halfway through writing the next record
+	_, _ = s.bufWriter.Write([]byte{0, 0, 1})
+	err = s.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen like we would after a restart
+	s, err = openSpill(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, ok := s.oldest()
+	if !ok {
+		t.Fatal("oldest() found no segment after reopening")
+	}
+	count := 0
+	_, err = s.replay(seq, 0, func(*nats.Msg) error {
+		count++
+		return nil
+	}, nil)
+	if err != nil || count != 1 {
+		t.Errorf("replay() = %v messages, err %v, want 1 message", count, err)
+	}
+}
+
+func Test_spillUndecodableRecord(t *testing.T) {
+	s, err := openSpill(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.append(testMsg(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a record with a valid checksum, that isn't an encoded message
+	record := []byte("not json")
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(record)))
+	binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(record))
+	_, _ = s.bufWriter.Write(header[:])
+	_, _ = s.bufWriter.Write(record)
+	err = s.append(testMsg(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seq, ok := s.oldest()
+	if !ok {
+		t.Fatal("oldest() found no segment")
+	}
+	var ids []string
+	skipped := 0
+	_, err = s.replay(seq, 0, func(msg *nats.Msg) error {
+		ids = append(ids, msg.Header.Get("Nats-Msg-Id"))
+		return nil
+	}, func(error) {
+		skipped++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "0" || ids[1] != "1" || skipped != 1 {
+		t.Errorf("replay() = %v, skipped %v, want [0 1], skipped 1", ids, skipped)
+	}
+}
+
+func TestPublisher_outage(t *testing.T) {
+	js := &fakeJetStream{}
+	p := New(js).WithQueue(10).WithWorkers(1).WithSpill(t.TempDir(), 0)
+	p.replayInterval = 10 * time.Millisecond
+	err := p.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	js.setDown(true)
+	for i := 0; i < 50; i++ {
+		p.Publish(testMsg(i))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for p.Stats().Spilled < 50 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	js.setDown(false)
+	for len(js.ids()) < 50 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	p.Close()
+
+	stats := p.Stats()
+	if stats.Dropped != 0 {
+		t.Errorf("Stats().Dropped = %v, want 0", stats.Dropped)
+	}
+	if stats.SpillBytes != 0 {
+		t.Errorf("Stats().SpillBytes = %v, want 0", stats.SpillBytes)
+	}
+	seen := make(map[string]bool)
+	for _, id := range js.ids() {
+		seen[id] = true
+	}
+	if len(seen) != 50 {
+		t.Errorf("published %v unique messages, want 50", len(seen))
+	}
+}
+
+func TestPublisher_dropWithoutSpill(t *testing.T) {
+	js := &fakeJetStream{down: true}
+	p := New(js).WithQueue(1).WithWorkers(1)
+	err := p.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		p.Publish(testMsg(i))
+	}
+	p.Close()
+
+	if stats := p.Stats(); stats.Dropped != 5 {
+		t.Errorf("Stats().Dropped = %v, want 5", stats.Dropped)
+	}
+}
+
+func TestPublisher_publishAfterClose(t *testing.T) {
+	js := &fakeJetStream{}
+	p := New(js).WithWorkers(1)
+	err := p.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	// must not panic on the closed queue
+	p.Publish(testMsg(0))
+	p.Close()
+
+	if stats := p.Stats(); stats.Dropped != 1 {
+		t.Errorf("Stats().Dropped = %v, want 1", stats.Dropped)
+	}
+}
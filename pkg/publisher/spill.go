@@ -0,0 +1,301 @@
+package publisher
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// SegmentSize is the size at which the spill starts writing to a new segment file
+var SegmentSize int64 = 16 << 20
+
+const segmentExt = ".seg"
+
+// spill stores messages in append-only segment files, so they can be replayed in order once NATS is available again.
+// Records are framed as a big-endian uint32 length, a crc32 checksum & the encoded message.
+type spill struct {
+	dir      string
+	maxBytes int64
+
+	mx sync.Mutex
+	// segments contains the sequence numbers of the segment files on disk, oldest first, including the one being written
+	segments []uint64
+	sizes    map[uint64]int64
+	size     int64
+
+	writer    *os.File
+	bufWriter *bufio.Writer
+	writeSeq  uint64
+}
+
+func openSpill(dir string, maxBytes int64) (*spill, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &spill{
+		dir:      dir,
+		maxBytes: maxBytes,
+		sizes:    make(map[uint64]int64),
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, seq)
+		s.sizes[seq] = info.Size()
+		s.size += info.Size()
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+	if len(s.segments) > 0 {
+		s.writeSeq = s.segments[len(s.segments)-1]
+	}
+	return s, nil
+}
+
+// append writes the message to the current segment, starting a new segment when it's full
+func (s *spill) append(msg *nats.Msg) error {
+	record, err := encodeRecord(msg)
+	if err != nil {
+		return err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	length := int64(len(record)) + 8
+	if s.maxBytes > 0 && s.size+length > s.maxBytes {
+		return ErrSpillFull
+	}
+
+	if s.writer == nil || s.sizes[s.writeSeq] >= SegmentSize {
+		err = s.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(record)))
+	binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(record))
+	_, err = s.bufWriter.Write(header[:])
+	if err != nil {
+		return err
+	}
+	_, err = s.bufWriter.Write(record)
+	if err != nil {
+		return err
+	}
+
+	s.sizes[s.writeSeq] += length
+	s.size += length
+	return nil
+}
+
+// flush writes buffered records of the current segment to disk
+func (s *spill) flush() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.bufWriter == nil {
+		return nil
+	}
+	return s.bufWriter.Flush()
+}
+
+// rotate closes the current segment, and starts a new one
+func (s *spill) rotate() error {
+	err := s.closeWriter()
+	if err != nil {
+		return err
+	}
+
+	s.writeSeq++
+	file, err := os.OpenFile(s.path(s.writeSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.writer = file
+	s.bufWriter = bufio.NewWriter(file)
+	s.segments = append(s.segments, s.writeSeq)
+	s.sizes[s.writeSeq] = 0
+	return nil
+}
+
+func (s *spill) closeWriter() error {
+	if s.writer == nil {
+		return nil
+	}
+	err := s.bufWriter.Flush()
+	if err != nil {
+		return err
+	}
+	err = s.writer.Close()
+	s.writer = nil
+	s.bufWriter = nil
+	return err
+}
+
+// oldest returns the oldest segment, if it's still being written to, it's closed first so it can be replayed
+func (s *spill) oldest() (uint64, bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if len(s.segments) == 0 {
+		return 0, false
+	}
+	seq := s.segments[0]
+	if seq == s.writeSeq && s.writer != nil {
+		if s.sizes[seq] == 0 {
+			return 0, false
+		}
+		err := s.closeWriter()
+		if err != nil {
+			return 0, false
+		}
+	}
+	return seq, true
+}
+
+// remove deletes a segment after it has been replayed
+func (s *spill) remove(seq uint64) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for i, segment := range s.segments {
+		if segment == seq {
+			s.segments = append(s.segments[:i], s.segments[i+1:]...)
+			break
+		}
+	}
+	s.size -= s.sizes[seq]
+	delete(s.sizes, seq)
+	return os.Remove(s.path(seq))
+}
+
+// bytes returns the total size of all segments
+func (s *spill) bytes() int64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.size
+}
+
+func (s *spill) close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.closeWriter()
+}
+
+func (s *spill) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%v", seq, segmentExt))
+}
+
+// replay runs publish for every record in the segment, starting at offset.
+// Returns the offset of the first record that wasn't published, so a failed replay can continue where it left off.
+// Records that pass their checksum but don't decode are passed to skip, if set, and replay continues after them.
+func (s *spill) replay(seq uint64, offset int64, publish func(msg *nats.Msg) error, skip func(err error)) (int64, error) {
+	file, err := os.Open(s.path(seq))
+	if err != nil {
+		return offset, err
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return offset, err
+	}
+	reader := bufio.NewReader(file)
+
+	for {
+		var header [8]byte
+		_, err = io.ReadFull(reader, header[:])
+		if errors.Is(err, io.EOF) {
+			return offset, nil
+		}
+		if err != nil {
+			// a partial header means the last write was torn, there's nothing after it
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return offset, nil
+			}
+			return offset, err
+		}
+
+		record := make([]byte, binary.BigEndian.Uint32(header[:4]))
+		_, err = io.ReadFull(reader, record)
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		if crc32.ChecksumIEEE(record) != binary.BigEndian.Uint32(header[4:]) {
+			return offset, ErrCorruptRecord
+		}
+
+		msg, err := decodeRecord(record)
+		if err != nil {
+			// retrying can't fix a record that doesn't decode, but the framing is intact, so the records after it can be replayed
+			if skip != nil {
+				skip(err)
+			}
+			offset += int64(len(record)) + 8
+			continue
+		}
+		err = publish(msg)
+		if err != nil {
+			return offset, err
+		}
+		offset += int64(len(record)) + 8
+	}
+}
+
+// record is the on-disk representation of a message, the header keeps the original Nats-Msg-Id for deduplication
+type record struct {
+	Subject string      `json:"subject"`
+	Header  nats.Header `json:"header,omitempty"`
+	Data    []byte      `json:"data"`
+}
+
+func encodeRecord(msg *nats.Msg) ([]byte, error) {
+	return json.Marshal(record{
+		Subject: msg.Subject,
+		Header:  msg.Header,
+		Data:    msg.Data,
+	})
+}
+
+func decodeRecord(data []byte) (*nats.Msg, error) {
+	r := record{}
+	err := json.Unmarshal(data, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &nats.Msg{
+		Subject: r.Subject,
+		Header:  r.Header,
+		Data:    r.Data,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package sharding
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Assignments stores an assignment of keys to members in a redis hash, with a version that's incremented on every save,
+// so members can cheaply check whether the assignment changed
+type Assignments struct {
+	client redis.Cmdable
+	key    string
+}
+
+// NewAssignments returns an assignment store, using the hash at key & the version at key:version
+func NewAssignments(client redis.Cmdable, key string) *Assignments {
+	return &Assignments{
+		client: client,
+		key:    key,
+	}
+}
+
+// Save replaces the stored assignment & increments its version
+func (a *Assignments) Save(ctx context.Context, assignment map[string]string) error {
+	values := make([]any, 0, len(assignment)*2)
+	for key, member := range assignment {
+		values = append(values, key, member)
+	}
+
+	_, err := a.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, a.key)
+		if len(values) > 0 {
+			pipe.HSet(ctx, a.key, values...)
+		}
+		pipe.Incr(ctx, a.versionKey())
+		return nil
+	})
+	return err
+}
+
+// Version returns the version of the stored assignment, 0 if nothing has been saved yet
+func (a *Assignments) Version(ctx context.Context) (int64, error) {
+	version, err := a.client.Get(ctx, a.versionKey()).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// Load returns the stored assignment with its version
+func (a *Assignments) Load(ctx context.Context) (map[string]string, int64, error) {
+	var assignment *redis.MapStringStringCmd
+	var version *redis.StringCmd
+	_, err := a.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		assignment = pipe.HGetAll(ctx, a.key)
+		version = pipe.Get(ctx, a.versionKey())
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, 0, err
+	}
+
+	v, err := version.Int64()
+	if errors.Is(err, redis.Nil) {
+		return assignment.Val(), 0, nil
+	}
+	return assignment.Val(), v, err
+}
+
+func (a *Assignments) versionKey() string {
+	return a.key + ":version"
+}
@@ -0,0 +1,82 @@
+package sharding
+
+import (
+	"sort"
+)
+
+// Item is a key to assign, with its weight
+type Item struct {
+	Key    string
+	Weight int
+}
+
+// Balance assigns the items to the members, so the total weight of each member is about the same.
+// Items stay with their previous owner, as long as it's still a member and doesn't exceed its fair share of the total weight
+// by more than tolerance (e.g. 0.1 for 10%). This keeps the amount of moved items low between assignments.
+// Items heavier than a fair share get a member to themselves where possible.
+func Balance(items []Item, members []string, previous map[string]string, tolerance float64) map[string]string {
+	assignment := make(map[string]string, len(items))
+	if len(members) == 0 {
+		return assignment
+	}
+
+	sorted := make([]Item, len(items))
+	copy(sorted, items)
+	// heaviest first, sorting by key as well keeps the result deterministic
+	sort.Slice(sorted, func(i, j int) bool {
+		if weightOf(sorted[i]) != weightOf(sorted[j]) {
+			return weightOf(sorted[i]) > weightOf(sorted[j])
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	total := 0
+	for _, item := range sorted {
+		total += weightOf(item)
+	}
+	limit := float64(total) / float64(len(members)) * (1 + tolerance)
+
+	load := make(map[string]int, len(members))
+	for _, member := range members {
+		load[member] = 0
+	}
+
+	// keep items with their previous owner while it has room
+	var moved []Item
+	for _, item := range sorted {
+		owner, ok := previous[item.Key]
+		if _, alive := load[owner]; ok && alive && float64(load[owner]+weightOf(item)) <= limit {
+			assignment[item.Key] = owner
+			load[owner] += weightOf(item)
+			continue
+		}
+		moved = append(moved, item)
+	}
+
+	// assign the remaining items to the least loaded member, heaviest first
+	for _, item := range moved {
+		owner := leastLoaded(members, load)
+		assignment[item.Key] = owner
+		load[owner] += weightOf(item)
+	}
+
+	return assignment
+}
+
+func leastLoaded(members []string, load map[string]int) string {
+	owner := members[0]
+	for _, member := range members[1:] {
+		if load[member] < load[owner] {
+			owner = member
+		}
+	}
+	return owner
+}
+
+// weightOf counts items without a weight as 1, they still take up a spot on a connection
+func weightOf(item Item) int {
+	if item.Weight < 1 {
+		return 1
+	}
+	return item.Weight
+}
@@ -0,0 +1,99 @@
+package sharding
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBalance(t *testing.T) {
+	members := []string{"irc-reader-0", "irc-reader-1", "irc-reader-2"}
+
+	// a few heavy channels & a long tail of light ones
+	var items []Item
+	for i := 0; i < 300; i++ {
+		weight := 1
+		if i < 6 {
+			weight = 50
+		}
+		items = append(items, Item{Key: strconv.Itoa(i), Weight: weight})
+	}
+	total := 6*50 + 294
+
+	type args struct {
+		members  []string
+		previous map[string]string
+	}
+	tests := []struct {
+		name string
+		args args
+		// maxMoved is the maximum amount of items expected to change owner
+		maxMoved int
+	}{
+		{
+			name:     "no previous assignment",
+			args:     args{members: members},
+			maxMoved: len(items),
+		},
+		{
+			name: "everything on one member",
+			args: args{
+				members:  members,
+				previous: assignAll(items, "irc-reader-0"),
+			},
+			maxMoved: len(items),
+		},
+		{
+			name: "balanced stays put",
+			args: args{
+				members:  members,
+				previous: Balance(items, members, nil, 0.1),
+			},
+			maxMoved: 0,
+		},
+		{
+			name: "dead member",
+			args: args{
+				members:  members[:2],
+				previous: Balance(items, members, nil, 0.1),
+			},
+			// only the items of the dead member, plus a few to even out the load
+			maxMoved: len(items)/3 + 30,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Balance(items, tt.args.members, tt.args.previous, 0.1)
+			if len(got) != len(items) {
+				t.Fatalf("Balance() assigned %v items, want %v", len(got), len(items))
+			}
+
+			load := make(map[string]int)
+			moved := 0
+			for _, item := range items {
+				owner := got[item.Key]
+				load[owner] += weightOf(item)
+				if tt.args.previous[item.Key] != owner {
+					moved++
+				}
+			}
+
+			limit := float64(total) / float64(len(tt.args.members)) * 1.1
+			for _, member := range tt.args.members {
+				if float64(load[member]) > limit {
+					t.Errorf("member %v has load %v, want at most %v", member, load[member], limit)
+				}
+			}
+			if moved > tt.maxMoved {
+				t.Errorf("Balance() moved %v items, want at most %v", moved, tt.maxMoved)
+			}
+		})
+	}
+}
+
+func assignAll(items []Item, member string) map[string]string {
+	assignment := make(map[string]string, len(items))
+	for _, item := range items {
+		assignment[item.Key] = member
+	}
+	return assignment
+}
@@ -0,0 +1,101 @@
+package sharding
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// campaign takes the lock if it's free, or extends it if we already hold it
+var campaign = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// resign releases the lock, only if we hold it
+var resign = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Elector elects a single leader among its members, using a redis key holding the id of the leader with an expiry
+type Elector struct {
+	client redis.Scripter
+	key    string
+	id     string
+	ttl    time.Duration
+
+	mx     sync.Mutex
+	leader bool
+}
+
+// NewElector returns an elector for the member id, campaigning for the lock at key.
+// A leader that stops renewing its lock is replaced once the ttl expires.
+func NewElector(client redis.Scripter, key, id string, ttl time.Duration) *Elector {
+	return &Elector{
+		client: client,
+		key:    key,
+		id:     id,
+		ttl:    ttl,
+	}
+}
+
+// IsLeader returns true if we held the lock as of the last campaign
+func (e *Elector) IsLeader() bool {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	return e.leader
+}
+
+// Start blocks, campaigning for or renewing the lock every interval until ctx is cancelled.
+// The interval must be lower than the ttl.
+func (e *Elector) Start(ctx context.Context, interval time.Duration) {
+	e.campaign(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.campaign(ctx)
+		}
+	}
+}
+
+// Resign releases the lock if we're the leader, so another member can take over right away
+func (e *Elector) Resign(ctx context.Context) error {
+	e.mx.Lock()
+	e.leader = false
+	e.mx.Unlock()
+	return resign.Run(ctx, e.client, []string{e.key}, e.id).Err()
+}
+
+func (e *Elector) campaign(ctx context.Context) {
+	result, err := campaign.Run(ctx, e.client, []string{e.key}, e.id, e.ttl.Milliseconds()).Int()
+	// we can't tell whether we still hold the lock, so step down to be safe
+	leader := err == nil && result == 1
+	if err != nil {
+		zap.S().Errorw("failed to campaign for leadership", "error", err)
+	}
+
+	e.mx.Lock()
+	changed := e.leader != leader
+	e.leader = leader
+	e.mx.Unlock()
+
+	if changed {
+		zap.S().Infow("leadership changed", "id", e.id, "leader", leader)
+	}
+}
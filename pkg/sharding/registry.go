@@ -0,0 +1,125 @@
+package sharding
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Registry keeps track of the live members of a group in a redis sorted set, scored by the expiry of their lease.
+// Members renew their lease with every heartbeat, members that stop sending heartbeats are dropped once their lease expires.
+type Registry struct {
+	client redis.Cmdable
+	key    string
+	id     string
+	ttl    time.Duration
+
+	mx      sync.Mutex
+	members []string
+
+	onChange func(previous, current []string)
+}
+
+// NewRegistry returns a registry for the member id, using the sorted set at key.
+// Leases are compared against the local clock, so members should keep their clocks in sync.
+func NewRegistry(client redis.Cmdable, key, id string, ttl time.Duration) *Registry {
+	return &Registry{
+		client: client,
+		key:    key,
+		id:     id,
+		ttl:    ttl,
+	}
+}
+
+// OnChange sets a callback, executed when members join or leave, with the sorted members before & after the change
+func (r *Registry) OnChange(cb func(previous, current []string)) {
+	r.onChange = cb
+}
+
+// ID returns the id of this member
+func (r *Registry) ID() string {
+	return r.id
+}
+
+// Members returns the sorted ids of all live members, as of the last heartbeat
+func (r *Registry) Members() []string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]string(nil), r.members...)
+}
+
+// Register sends the first heartbeat, so Members is populated before the registry is started
+func (r *Registry) Register(ctx context.Context) error {
+	return r.heartbeat(ctx)
+}
+
+// Start blocks, sending a heartbeat every interval until ctx is cancelled. The interval must be lower than the ttl.
+func (r *Registry) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.heartbeat(ctx)
+			if err != nil {
+				// keep the last known members, we'd rather read channels twice than not at all
+				zap.S().Errorw("failed to send membership heartbeat", "error", err)
+			}
+		}
+	}
+}
+
+// Leave removes this member, so the other members can take over immediately instead of waiting for the lease to expire
+func (r *Registry) Leave(ctx context.Context) error {
+	return r.client.ZRem(ctx, r.key, r.id).Err()
+}
+
+// heartbeat renews our lease, drops expired members & refreshes the member list
+func (r *Registry) heartbeat(ctx context.Context) error {
+	now := time.Now()
+	var members *redis.StringSliceCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, r.key, redis.Z{
+			Score:  float64(now.Add(r.ttl).UnixMilli()),
+			Member: r.id,
+		})
+		pipe.ZRemRangeByScore(ctx, r.key, "-inf", "("+strconv.FormatInt(now.UnixMilli(), 10))
+		members = pipe.ZRange(ctx, r.key, 0, -1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	current := members.Val()
+	sort.Strings(current)
+
+	r.mx.Lock()
+	previous := r.members
+	r.members = current
+	r.mx.Unlock()
+
+	if r.onChange != nil && !equal(previous, current) {
+		r.onChange(previous, current)
+	}
+	return nil
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
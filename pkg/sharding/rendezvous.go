@@ -0,0 +1,75 @@
+package sharding
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// Owner returns the member the key is assigned to using rendezvous (highest random weight) hashing,
+// empty if there are no members. Adding or removing a member only moves the keys assigned to that member.
+func Owner(key string, members []string) string {
+	var owner string
+	var highest uint64
+	for _, member := range members {
+		score := weight(member, key)
+		// ties are practically impossible, but compare members as well, so the result doesn't depend on the order
+		if owner == "" || score > highest || (score == highest && member < owner) {
+			owner = member
+			highest = score
+		}
+	}
+	return owner
+}
+
+// Owners returns the n members with the highest weight for the key, in order of weight, so the first one is the Owner.
+// Returns all members if there are fewer than n. Used for keys that are assigned to multiple members for redundancy.
+func Owners(key string, members []string, n int) []string {
+	if n <= 1 {
+		if owner := Owner(key, members); owner != "" {
+			return []string{owner}
+		}
+		return nil
+	}
+
+	type scored struct {
+		member string
+		score  uint64
+	}
+	scores := make([]scored, 0, len(members))
+	for _, member := range members {
+		scores = append(scores, scored{member, weight(member, key)})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].score == scores[j].score {
+			return scores[i].member < scores[j].member
+		}
+		return scores[i].score > scores[j].score
+	})
+
+	if n > len(scores) {
+		n = len(scores)
+	}
+	owners := make([]string, 0, n)
+	for _, s := range scores[:n] {
+		owners = append(owners, s.member)
+	}
+	return owners
+}
+
+func weight(member, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(member))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return mix(h.Sum64())
+}
+
+// mix is the splitmix64 finalizer, fnv alone doesn't spread similar keys like sequential user IDs well enough
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,96 @@
+package sharding
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestOwner(t *testing.T) {
+	const keys = 10000
+	members := []string{"irc-reader-0", "irc-reader-1", "irc-reader-2"}
+
+	counts := make(map[string]int)
+	owners := make(map[string]string, keys)
+	for i := 0; i < keys; i++ {
+		key := strconv.Itoa(i)
+		owner := Owner(key, members)
+		owners[key] = owner
+		counts[owner]++
+	}
+
+	// every member should get roughly a third of the keys
+	for _, member := range members {
+		if counts[member] < keys/3*8/10 || counts[member] > keys/3*12/10 {
+			t.Errorf("member %v owns %v keys, want about %v", member, counts[member], keys/3)
+		}
+	}
+
+	// scaling up should only move keys to the new member
+	scaled := append(members, "irc-reader-3")
+	moved := 0
+	for key, previous := range owners {
+		owner := Owner(key, scaled)
+		if owner == previous {
+			continue
+		}
+		if owner != "irc-reader-3" {
+			t.Fatalf("key %v moved from %v to %v, want only moves to the new member", key, previous, owner)
+		}
+		moved++
+	}
+	if moved < keys/4*8/10 || moved > keys/4*12/10 {
+		t.Errorf("moved %v keys, want about %v", moved, keys/4)
+	}
+
+	// the order of members shouldn't matter
+	reversed := []string{"irc-reader-2", "irc-reader-1", "irc-reader-0"}
+	for key, previous := range owners {
+		if owner := Owner(key, reversed); owner != previous {
+			t.Fatalf("Owner(%v) = %v with reversed members, want %v", key, owner, previous)
+		}
+	}
+
+	if owner := Owner("1", nil); owner != "" {
+		t.Errorf("Owner() without members = %v, want empty", owner)
+	}
+}
+
+func TestOwners(t *testing.T) {
+	members := []string{"irc-reader-0", "irc-reader-1", "irc-reader-2", "irc-reader-3"}
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		owners := Owners(key, members, 3)
+		if len(owners) != 3 {
+			t.Fatalf("Owners(%v) = %v, want 3 members", key, owners)
+		}
+		if owners[0] != Owner(key, members) {
+			t.Fatalf("Owners(%v)[0] = %v, want Owner() %v", key, owners[0], Owner(key, members))
+		}
+		seen := make(map[string]bool)
+		for _, owner := range owners {
+			if seen[owner] {
+				t.Fatalf("Owners(%v) = %v, want unique members", key, owners)
+			}
+			seen[owner] = true
+		}
+
+		// losing a member should keep the remaining owners, and only add the next one
+		var remaining []string
+		for _, member := range members {
+			if member != owners[0] {
+				remaining = append(remaining, member)
+			}
+		}
+		after := Owners(key, remaining, 3)
+		if after[0] != owners[1] || after[1] != owners[2] {
+			t.Fatalf("Owners(%v) = %v after removing %v, want %v first", key, after, owners[0], owners[1:])
+		}
+	}
+
+	if owners := Owners("1", members[:2], 3); len(owners) != 2 {
+		t.Errorf("Owners() = %v, want all 2 members", owners)
+	}
+	if owners := Owners("1", nil, 1); len(owners) != 0 {
+		t.Errorf("Owners() without members = %v, want none", owners)
+	}
+}
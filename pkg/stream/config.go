@@ -0,0 +1,119 @@
+package stream
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Config overrides the limits & storage of a declared stream, decoded from the service config.
+// Zero values keep the value declared by the service.
+type Config struct {
+	// Retention is either "limits", "interest" or "workqueue"
+	Retention string
+	// Storage is either "file" or "memory"
+	Storage string
+	// Discard is the policy once a limit is reached, either "old" or "new".
+	// "new" makes publishes fail once the stream is full, e.g. when consumers fall behind on an interest stream.
+	Discard    string
+	Replicas   int
+	MaxAge     time.Duration
+	MaxBytes   int64
+	MaxMsgs    int64
+	Duplicates time.Duration
+}
+
+// Apply overrides the declared stream config with the values that are set
+func (c Config) Apply(cfg *nats.StreamConfig) error {
+	if c.Retention != "" {
+		retention, err := parseRetention(c.Retention)
+		if err != nil {
+			return err
+		}
+		cfg.Retention = retention
+	}
+	if c.Storage != "" {
+		storage, err := parseStorage(c.Storage)
+		if err != nil {
+			return err
+		}
+		cfg.Storage = storage
+	}
+	if c.Discard != "" {
+		discard, err := parseDiscard(c.Discard)
+		if err != nil {
+			return err
+		}
+		cfg.Discard = discard
+	}
+	if c.Replicas > 0 {
+		cfg.Replicas = c.Replicas
+	}
+	if c.MaxAge > 0 {
+		cfg.MaxAge = c.MaxAge
+	}
+	if c.MaxBytes != 0 {
+		cfg.MaxBytes = c.MaxBytes
+	}
+	if c.MaxMsgs != 0 {
+		cfg.MaxMsgs = c.MaxMsgs
+	}
+	if c.Duplicates > 0 {
+		cfg.Duplicates = c.Duplicates
+	}
+	return nil
+}
+
+// Consumer overrides the delivery settings of a declared consumer, decoded from the service config.
+// Zero values keep the value declared by the service.
+type Consumer struct {
+	AckWait       time.Duration
+	MaxDeliver    int
+	MaxAckPending int
+}
+
+// Apply overrides the declared consumer config with the values that are set
+func (c Consumer) Apply(cfg *nats.ConsumerConfig) {
+	if c.AckWait > 0 {
+		cfg.AckWait = c.AckWait
+	}
+	if c.MaxDeliver != 0 {
+		cfg.MaxDeliver = c.MaxDeliver
+	}
+	if c.MaxAckPending != 0 {
+		cfg.MaxAckPending = c.MaxAckPending
+	}
+}
+
+func parseRetention(s string) (nats.RetentionPolicy, error) {
+	switch strings.ToLower(s) {
+	case "limits":
+		return nats.LimitsPolicy, nil
+	case "interest":
+		return nats.InterestPolicy, nil
+	case "workqueue":
+		return nats.WorkQueuePolicy, nil
+	}
+	return 0, ErrUnknownRetention
+}
+
+func parseStorage(s string) (nats.StorageType, error) {
+	switch strings.ToLower(s) {
+	case "file":
+		return nats.FileStorage, nil
+	case "memory":
+		return nats.MemoryStorage, nil
+	}
+	return 0, ErrUnknownStorage
+}
+
+func parseDiscard(s string) (nats.DiscardPolicy, error) {
+	switch strings.ToLower(s) {
+	case "old":
+		return nats.DiscardOld, nil
+	case "new":
+		return nats.DiscardNew, nil
+	}
+	return 0, ErrUnknownDiscard
+}
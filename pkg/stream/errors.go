@@ -0,0 +1,11 @@
+package stream
+
+import "errors"
+
+var (
+	ErrUnknownRetention = errors.New("unknown retention policy, must be limits, interest or workqueue")
+	ErrUnknownStorage   = errors.New("unknown storage type, must be file or memory")
+	ErrUnknownDiscard   = errors.New("unknown discard policy, must be old or new")
+	// ErrSubjectNotCovered is returned when a stream we don't own doesn't contain the subjects we need
+	ErrSubjectNotCovered = errors.New("subject not covered by stream")
+)
@@ -0,0 +1,230 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// Ensure makes sure the declared stream exists. Streams are shared between services, only the owner of a stream updates it
+// when the existing stream drifted from its declaration, other services log the drift & only check that the subjects they need
+// are in the stream, so services don't overwrite each other's view of the stream.
+func Ensure(js nats.JetStreamManager, declared *nats.StreamConfig, owner bool) error {
+	info, err := js.StreamInfo(declared.Name)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(declared)
+		if err != nil {
+			return fmt.Errorf("add stream %v: %w", declared.Name, err)
+		}
+		zap.S().Infow("created stream", "stream", declared.Name, "subjects", declared.Subjects)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	drift := Drift(declared, &info.Config)
+	if len(drift) == 0 {
+		return nil
+	}
+
+	if !owner {
+		zap.S().Warnw("stream differs from our declaration, leaving it to its owner", "stream", declared.Name, "drift", drift)
+		for _, subject := range declared.Subjects {
+			if !coveredBy(subject, info.Config.Subjects) {
+				return fmt.Errorf("%w: %v in %v", ErrSubjectNotCovered, subject, declared.Name)
+			}
+		}
+		return nil
+	}
+
+	zap.S().Infow("updating stream that drifted from its declaration", "stream", declared.Name, "drift", drift)
+	_, err = js.UpdateStream(declared)
+	if err != nil {
+		// e.g. the storage type & retention policy can't be changed, the stream has to be recreated by hand
+		return fmt.Errorf("update stream %v: %w", declared.Name, err)
+	}
+	return nil
+}
+
+// EnsureConsumer makes sure the declared durable consumer exists, and updates it when it drifted from its declaration.
+// A consumer belongs to a single service, so unlike streams it's always updated.
+func EnsureConsumer(js nats.JetStreamManager, stream string, declared *nats.ConsumerConfig) error {
+	info, err := js.ConsumerInfo(stream, declared.Durable)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		_, err = js.AddConsumer(stream, declared)
+		if err != nil {
+			return fmt.Errorf("add consumer %v: %w", declared.Durable, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	drift := ConsumerDrift(declared, &info.Config)
+	if len(drift) == 0 {
+		return nil
+	}
+	zap.S().Infow("updating consumer that drifted from its declaration", "stream", stream, "consumer", declared.Durable, "drift", drift)
+	_, err = js.UpdateConsumer(stream, declared)
+	if err != nil {
+		return fmt.Errorf("update consumer %v: %w", declared.Durable, err)
+	}
+	return nil
+}
+
+// Drift returns the differences between the declared & existing stream, as "field: declared != existing".
+// Unset values in the declaration are compared to the defaults the server fills in.
+func Drift(declared, existing *nats.StreamConfig) []string {
+	d := normalize(*declared)
+	e := normalize(*existing)
+
+	var drift []string
+	add := func(field string, declared, existing any) {
+		drift = append(drift, fmt.Sprintf("%v: %v != %v", field, declared, existing))
+	}
+	if !sameSubjects(d.Subjects, e.Subjects) {
+		add("subjects", d.Subjects, e.Subjects)
+	}
+	if d.Retention != e.Retention {
+		add("retention", d.Retention, e.Retention)
+	}
+	if d.Storage != e.Storage {
+		add("storage", d.Storage, e.Storage)
+	}
+	if d.Discard != e.Discard {
+		add("discard", d.Discard, e.Discard)
+	}
+	if d.Replicas != e.Replicas {
+		add("replicas", d.Replicas, e.Replicas)
+	}
+	if d.MaxAge != e.MaxAge {
+		add("max_age", d.MaxAge, e.MaxAge)
+	}
+	if d.MaxBytes != e.MaxBytes {
+		add("max_bytes", d.MaxBytes, e.MaxBytes)
+	}
+	if d.MaxMsgs != e.MaxMsgs {
+		add("max_msgs", d.MaxMsgs, e.MaxMsgs)
+	}
+	if d.Duplicates != e.Duplicates {
+		add("duplicates", d.Duplicates, e.Duplicates)
+	}
+	return drift
+}
+
+// ConsumerDrift returns the differences between the declared & existing consumer, as "field: declared != existing"
+func ConsumerDrift(declared, existing *nats.ConsumerConfig) []string {
+	d := normalizeConsumer(*declared)
+	e := normalizeConsumer(*existing)
+
+	var drift []string
+	add := func(field string, declared, existing any) {
+		drift = append(drift, fmt.Sprintf("%v: %v != %v", field, declared, existing))
+	}
+	if d.FilterSubject != e.FilterSubject {
+		add("filter_subject", d.FilterSubject, e.FilterSubject)
+	}
+	if d.DeliverPolicy != e.DeliverPolicy {
+		add("deliver_policy", d.DeliverPolicy, e.DeliverPolicy)
+	}
+	if d.AckPolicy != e.AckPolicy {
+		add("ack_policy", d.AckPolicy, e.AckPolicy)
+	}
+	if d.AckWait != e.AckWait {
+		add("ack_wait", d.AckWait, e.AckWait)
+	}
+	if d.MaxDeliver != e.MaxDeliver {
+		add("max_deliver", d.MaxDeliver, e.MaxDeliver)
+	}
+	if d.MaxAckPending != e.MaxAckPending {
+		add("max_ack_pending", d.MaxAckPending, e.MaxAckPending)
+	}
+	if d.InactiveThreshold != e.InactiveThreshold {
+		add("inactive_threshold", d.InactiveThreshold, e.InactiveThreshold)
+	}
+	return drift
+}
+
+// normalize fills in the defaults the server uses for unset values
+func normalize(cfg nats.StreamConfig) nats.StreamConfig {
+	if cfg.Replicas == 0 {
+		cfg.Replicas = 1
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = -1
+	}
+	if cfg.MaxMsgs == 0 {
+		cfg.MaxMsgs = -1
+	}
+	if cfg.Duplicates == 0 {
+		cfg.Duplicates = 2 * time.Minute
+		if cfg.MaxAge > 0 && cfg.MaxAge < cfg.Duplicates {
+			cfg.Duplicates = cfg.MaxAge
+		}
+	}
+	return cfg
+}
+
+func normalizeConsumer(cfg nats.ConsumerConfig) nats.ConsumerConfig {
+	if cfg.AckWait == 0 {
+		cfg.AckWait = 30 * time.Second
+	}
+	if cfg.MaxDeliver == 0 {
+		cfg.MaxDeliver = -1
+	}
+	if cfg.MaxAckPending == 0 {
+		cfg.MaxAckPending = 1000
+	}
+	return cfg
+}
+
+func sameSubjects(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// coveredBy returns true if every message published to subject matches one of the stream subjects
+func coveredBy(subject string, streamSubjects []string) bool {
+	for _, pattern := range streamSubjects {
+		if covers(pattern, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers returns true if the pattern matches every subject the other pattern matches, both can contain wildcards
+func covers(pattern, subject string) bool {
+	p := strings.Split(pattern, ".")
+	s := strings.Split(subject, ".")
+	for i, token := range p {
+		if token == ">" {
+			return len(s) > i
+		}
+		if i >= len(s) || s[i] == ">" {
+			return false
+		}
+		if token != "*" && token != s[i] {
+			return false
+		}
+	}
+	return len(p) == len(s)
+}
@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func Test_covers(t *testing.T) {
+	tests := []struct {
+		pattern string
+		subject string
+		want    bool
+	}{
+		{"irc.raw.twitch.>", "irc.raw.twitch.privmsg.>", true},
+		{"irc.raw.twitch.>", "irc.raw.twitch", false},
+		{"irc.raw.*.>", "irc.raw.twitch.privmsg.forsen", true},
+		{"irc.raw.twitch.*", "irc.raw.twitch.privmsg.forsen", false},
+		{"irc.raw.twitch.*", "irc.raw.twitch.>", false},
+		{"irc.raw.twitch.*", "irc.raw.twitch.*", true},
+		{"irc.normalized.twitch.>", "irc.raw.twitch.privmsg.>", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.subject, func(t *testing.T) {
+			if got := covers(tt.pattern, tt.subject); got != tt.want {
+				t.Errorf("covers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrift(t *testing.T) {
+	declared := &nats.StreamConfig{
+		Name:      "twitchIRC",
+		Subjects:  []string{"irc.raw.twitch.>", "irc.normalized.twitch.>"},
+		Retention: nats.InterestPolicy,
+		MaxAge:    time.Hour,
+	}
+
+	tests := []struct {
+		name     string
+		existing nats.StreamConfig
+		want     int
+	}{
+		{
+			name: "server defaults",
+			existing: nats.StreamConfig{
+				Name:       "twitchIRC",
+				Subjects:   []string{"irc.normalized.twitch.>", "irc.raw.twitch.>"},
+				Retention:  nats.InterestPolicy,
+				MaxAge:     time.Hour,
+				Replicas:   1,
+				MaxBytes:   -1,
+				MaxMsgs:    -1,
+				Duplicates: 2 * time.Minute,
+			},
+			want: 0,
+		},
+		{
+			name: "discard & max age",
+			existing: nats.StreamConfig{
+				Name:      "twitchIRC",
+				Subjects:  []string{"irc.raw.twitch.>", "irc.normalized.twitch.>"},
+				Retention: nats.InterestPolicy,
+				Discard:   nats.DiscardNew,
+				MaxAge:    24 * time.Hour,
+			},
+			want: 2,
+		},
+		{
+			name: "subjects",
+			existing: nats.StreamConfig{
+				Name:      "twitchIRC",
+				Subjects:  []string{"irc.raw.twitch.>"},
+				Retention: nats.InterestPolicy,
+				MaxAge:    time.Hour,
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Drift(declared, &tt.existing); len(got) != tt.want {
+				t.Errorf("Drift() = %v, want %v differences", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Apply(t *testing.T) {
+	cfg := &nats.StreamConfig{
+		Retention:  nats.InterestPolicy,
+		MaxAge:     time.Hour,
+		Duplicates: time.Minute,
+	}
+	err := Config{Discard: "new", Storage: "memory", MaxAge: 24 * time.Hour}.Apply(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Discard != nats.DiscardNew || cfg.Storage != nats.MemoryStorage || cfg.MaxAge != 24*time.Hour {
+		t.Errorf("Apply() = %+v, want overridden discard, storage & max age", cfg)
+	}
+	if cfg.Retention != nats.InterestPolicy || cfg.Duplicates != time.Minute {
+		t.Errorf("Apply() = %+v, want declared retention & duplicates", cfg)
+	}
+
+	if err = (Config{Retention: "forever"}).Apply(cfg); err != ErrUnknownRetention {
+		t.Errorf("Apply() error = %v, want %v", err, ErrUnknownRetention)
+	}
+}
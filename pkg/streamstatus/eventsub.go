@@ -0,0 +1,130 @@
+package streamstatus
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/eventsub"
+)
+
+// Subscriber manages EventSub subscriptions & gets the current stream status, implemented by helix.Client.
+// WebSocket subscriptions require the client to use a user access token.
+type Subscriber interface {
+	StreamGetter
+	eventsub.Subscriber
+}
+
+// EventSub implements Source using the stream.online & stream.offline EventSub subscriptions.
+// The Helix streams endpoint is used once for the initial status of each channel, and after reconnecting.
+// Each channel takes 2 subscriptions, so a single session can track up to half of eventsub.MaxSubscriptions channels.
+type EventSub struct {
+	client        *eventsub.Client
+	subscriber    Subscriber
+	subscriptions *eventsub.Subscriptions
+
+	cb func(Change)
+}
+
+// NewEventSub returns an EventSub source, using the EventSub client for notifications & subscriber to manage subscriptions
+func NewEventSub(client *eventsub.Client, subscriber Subscriber) *EventSub {
+	return &EventSub{
+		client:        client,
+		subscriber:    subscriber,
+		subscriptions: eventsub.NewSubscriptions(client, subscriber),
+	}
+}
+
+// Start connects to EventSub & sends stream status changes to cb, reconnecting until ctx is cancelled
+func (e *EventSub) Start(ctx context.Context, cb func(Change)) error {
+	e.cb = cb
+
+	// channels could've gone live or offline while we were disconnected
+	e.subscriptions.OnResubscribe(func(channelIDs []string) {
+		err := e.sendStatus(ctx, channelIDs...)
+		if err != nil {
+			zap.S().Errorw("failed to get stream status", "error", err)
+		}
+	})
+	e.client.OnNotification(e.handleNotification)
+	e.client.OnRevocation(func(subscription eventsub.Subscription) {
+		zap.S().Warnw("eventsub subscription revoked", "type", subscription.Type, "status", subscription.Status, "condition", subscription.Condition)
+	})
+
+	return e.client.Run(ctx, 5*time.Second)
+}
+
+// Add subscribes to the stream status of the given channel, and sends its current status.
+// Returns eventsub.ErrSubscriptionLimit if the session can't take any more subscriptions.
+func (e *EventSub) Add(ctx context.Context, channelID string) error {
+	if e.subscriptions.Has(channelID) {
+		return nil
+	}
+	condition := map[string]string{"broadcaster_user_id": channelID}
+	err := e.subscriptions.Add(ctx, channelID,
+		eventsub.Topic{Type: eventsub.StreamOnline, Version: "1", Condition: condition},
+		eventsub.Topic{Type: eventsub.StreamOffline, Version: "1", Condition: condition},
+	)
+	if err != nil {
+		return err
+	}
+	// without a session, the status is sent once the subscriptions are created
+	if e.client.SessionID() == "" {
+		return nil
+	}
+	return e.sendStatus(ctx, channelID)
+}
+
+// Remove deletes the subscriptions for the given channel
+func (e *EventSub) Remove(ctx context.Context, channelID string) error {
+	return e.subscriptions.Remove(ctx, channelID)
+}
+
+// sendStatus gets the current stream status of the given channels from Helix
+func (e *EventSub) sendStatus(ctx context.Context, channelIDs ...string) error {
+	if len(channelIDs) == 0 {
+		return nil
+	}
+	streams, err := e.subscriber.GetStreams(ctx, channelIDs...)
+	if err != nil {
+		return err
+	}
+
+	live := make(map[string]bool, len(streams))
+	for _, stream := range streams {
+		live[stream.UserID] = true
+	}
+	for _, id := range channelIDs {
+		e.send(Change{ChannelID: id, Live: live[id]})
+	}
+	return nil
+}
+
+func (e *EventSub) handleNotification(notification *eventsub.NotificationPayload) {
+	switch notification.Subscription.Type {
+	case eventsub.StreamOnline:
+		event := &eventsub.StreamOnlineEvent{}
+		err := json.Unmarshal(notification.Event, event)
+		if err != nil {
+			zap.S().Errorw("failed to decode stream.online event", "error", err)
+			return
+		}
+		e.send(Change{ChannelID: event.BroadcasterUserID, Live: true})
+	case eventsub.StreamOffline:
+		event := &eventsub.StreamOfflineEvent{}
+		err := json.Unmarshal(notification.Event, event)
+		if err != nil {
+			zap.S().Errorw("failed to decode stream.offline event", "error", err)
+			return
+		}
+		e.send(Change{ChannelID: event.BroadcasterUserID, Live: false})
+	}
+}
+
+func (e *EventSub) send(change Change) {
+	if e.cb != nil {
+		e.cb(change)
+	}
+}
@@ -0,0 +1,124 @@
+package streamstatus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/seventv/7tv-bot/pkg/helix"
+)
+
+// StreamGetter returns the streams of the given user IDs that are currently live, implemented by helix.Client
+type StreamGetter interface {
+	GetStreams(ctx context.Context, userIDs ...string) ([]helix.Stream, error)
+}
+
+// Poller implements Source by polling the Helix streams endpoint
+type Poller struct {
+	client   StreamGetter
+	interval time.Duration
+
+	mx sync.Mutex
+	// channels maps the tracked channel IDs to whether they were live during the last poll
+	channels map[string]bool
+	// added contains channels that have been added since the last poll, so we always send their initial status
+	added map[string]struct{}
+}
+
+// NewPoller returns a Poller, checking the stream status of all tracked channels every interval
+func NewPoller(client StreamGetter, interval time.Duration) *Poller {
+	return &Poller{
+		client:   client,
+		interval: interval,
+		channels: make(map[string]bool),
+		added:    make(map[string]struct{}),
+	}
+}
+
+// Start polls the stream status of all tracked channels every interval, until ctx is cancelled
+func (p *Poller) Start(ctx context.Context, cb func(Change)) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		err := p.poll(ctx, cb)
+		if err != nil {
+			zap.S().Errorw("failed to poll stream status", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Add starts tracking the given channel, its status is sent on the next poll
+func (p *Poller) Add(_ context.Context, channelID string) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if _, ok := p.channels[channelID]; ok {
+		return nil
+	}
+	p.channels[channelID] = false
+	p.added[channelID] = struct{}{}
+	return nil
+}
+
+// Remove stops tracking the given channel
+func (p *Poller) Remove(_ context.Context, channelID string) error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	delete(p.channels, channelID)
+	delete(p.added, channelID)
+	return nil
+}
+
+func (p *Poller) poll(ctx context.Context, cb func(Change)) error {
+	p.mx.Lock()
+	ids := make([]string, 0, len(p.channels))
+	for id := range p.channels {
+		ids = append(ids, id)
+	}
+	p.mx.Unlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	streams, err := p.client.GetStreams(ctx, ids...)
+	if err != nil {
+		return err
+	}
+
+	live := make(map[string]bool, len(streams))
+	for _, stream := range streams {
+		live[stream.UserID] = true
+	}
+
+	var changes []Change
+
+	p.mx.Lock()
+	for _, id := range ids {
+		wasLive, ok := p.channels[id]
+		// channel was removed while we were polling
+		if !ok {
+			continue
+		}
+		_, isNew := p.added[id]
+		if wasLive == live[id] && !isNew {
+			continue
+		}
+		p.channels[id] = live[id]
+		delete(p.added, id)
+		changes = append(changes, Change{ChannelID: id, Live: live[id]})
+	}
+	p.mx.Unlock()
+
+	for _, change := range changes {
+		cb(change)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package streamstatus
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/seventv/7tv-bot/pkg/helix"
+)
+
+type fakeStreams struct {
+	live map[string]bool
+}
+
+func (f *fakeStreams) GetStreams(_ context.Context, userIDs ...string) ([]helix.Stream, error) {
+	var result []helix.Stream
+	for _, id := range userIDs {
+		if f.live[id] {
+			result = append(result, helix.Stream{UserID: id})
+		}
+	}
+	return result, nil
+}
+
+func TestPoller_poll(t *testing.T) {
+	streams := &fakeStreams{live: map[string]bool{"1": true}}
+	p := NewPoller(streams, 0)
+	p.Add(context.Background(), "1")
+	p.Add(context.Background(), "2")
+
+	tests := []struct {
+		name   string
+		update func()
+		want   []Change
+	}{
+		{
+			name:   "Initial",
+			update: func() {},
+			want: []Change{
+				{ChannelID: "1", Live: true},
+				{ChannelID: "2", Live: false},
+			},
+		},
+		{
+			name:   "Unchanged",
+			update: func() {},
+			want:   nil,
+		},
+		{
+			name: "Changed",
+			update: func() {
+				streams.live = map[string]bool{"2": true}
+			},
+			want: []Change{
+				{ChannelID: "1", Live: false},
+				{ChannelID: "2", Live: true},
+			},
+		},
+		{
+			name: "Added",
+			update: func() {
+				p.Add(context.Background(), "3")
+			},
+			want: []Change{
+				{ChannelID: "3", Live: false},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.update()
+			var got []Change
+			err := p.poll(context.Background(), func(change Change) {
+				got = append(got, change)
+			})
+			if err != nil {
+				t.Fatalf("poll() error = %v", err)
+			}
+			sort.Slice(got, func(i, j int) bool {
+				return got[i].ChannelID < got[j].ChannelID
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("poll() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
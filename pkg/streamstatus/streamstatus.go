@@ -0,0 +1,20 @@
+package streamstatus
+
+import "context"
+
+// Change is sent when a channel goes live or offline
+type Change struct {
+	ChannelID string
+	Live      bool
+}
+
+// Source notifies about channels going live or offline
+type Source interface {
+	// Start blocks, sending stream status changes of the tracked channels to cb until ctx is cancelled or an error occurs.
+	// The current status of a channel is sent when it starts being tracked.
+	Start(ctx context.Context, cb func(Change)) error
+	// Add starts tracking the stream status of the given channel ID
+	Add(ctx context.Context, channelID string) error
+	// Remove stops tracking the stream status of the given channel ID
+	Remove(ctx context.Context, channelID string) error
+}
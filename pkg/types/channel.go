@@ -3,13 +3,24 @@ package types
 import "time"
 
 // Channel is the struct we use to decode data from mongo
-// TODO: keep track of username changes/history?
 type Channel struct {
-	ID        int64     `bson:"user_id" json:"user_id"`
-	Flags     uint32    `bson:"flags,omitempty" json:"flags"`
-	Username  string    `bson:"username" json:"username"`
-	Platform  string    `bson:"platform" json:"platform"`
-	Weight    int       `bson:"weight" json:"weight"`
+	ID       int64  `bson:"user_id" json:"user_id"`
+	Flags    uint32 `bson:"flags,omitempty" json:"flags"`
+	Username string `bson:"username" json:"username"`
+	Platform string `bson:"platform" json:"platform"`
+	Weight   int    `bson:"weight" json:"weight"`
+	// Replicas is the amount of IRC readers reading the channel at the same time, so it has no gaps when a reader crashes.
+	// Duplicate messages are filtered out by JetStream. 0 or 1 means the channel is read by a single reader.
+	Replicas  int       `bson:"replicas,omitempty" json:"replicas,omitempty"`
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	// UsernameHistory contains previous usernames of the channel, oldest first
+	UsernameHistory []UsernameChange `bson:"username_history,omitempty" json:"username_history,omitempty"`
+}
+
+// UsernameChange is an entry in the username history of a channel
+type UsernameChange struct {
+	// Username is the username the channel had before the change
+	Username  string    `bson:"username" json:"username"`
+	ChangedAt time.Time `bson:"changed_at" json:"changed_at"`
 }
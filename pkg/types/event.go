@@ -0,0 +1,15 @@
+package types
+
+import "time"
+
+// ChannelEventVersion is the version of the ChannelEvent envelope, incremented on breaking changes
+const ChannelEventVersion = 1
+
+// ChannelEvent is a change to a channel, published by the API to JetStream & consumed by the IRC readers
+type ChannelEvent struct {
+	Version int `json:"version"`
+	// Op is the database operation, either INSERT, UPDATE or DELETE
+	Op        string    `json:"op"`
+	Channel   Channel   `json:"channel"`
+	Timestamp time.Time `json:"timestamp"`
+}
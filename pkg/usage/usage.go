@@ -0,0 +1,38 @@
+package usage
+
+import "time"
+
+// Version of the usage schema, incremented on breaking changes
+const Version = 1
+
+// Global is the last token of the subject the usage of all channels is published to
+const Global = "global"
+
+// Usage is the emote usage of a channel, or of all channels, during [Start, End).
+// It's published as JSON to <prefix>.<channel id> for a channel, and <prefix>.global for all channels.
+// Only intervals in which emotes were used are published.
+type Usage struct {
+	Version int `json:"version"`
+	// ChannelID is the Twitch user ID of the channel, empty for the usage of all channels
+	ChannelID string    `json:"channel_id,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	// Emotes are sorted by count, most used first
+	Emotes []Emote `json:"emotes"`
+}
+
+// Emote is a 7TV emote & the amount of times it was used
+type Emote struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Count int    `json:"count"`
+}
+
+// Subject returns the subject the usage of the channel is published to, or the global subject if channelID is empty
+func Subject(prefix, channelID string) string {
+	if channelID == "" {
+		return prefix + "." + Global
+	}
+	return prefix + "." + channelID
+}
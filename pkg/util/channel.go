@@ -16,5 +16,8 @@ func VerifyChannel(channel types.Channel) bool {
 	if channel.Platform == "" {
 		return false
 	}
+	if channel.Replicas < 0 {
+		return false
+	}
 	return true
 }
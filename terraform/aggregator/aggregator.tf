@@ -32,6 +32,7 @@ resource "kubernetes_secret" "app" {
       nats_url         = "nats.database.svc.cluster.local:4222"
       nats_irc_raw     = var.nats_irc_raw_subject
       nats_stream      = var.nats_twitch_irc_stream
+      nats_duplicates  = "1m"
       mongo_uri        = var.infra.mongodb_uri
       mongo_username   = var.infra.mongodb_user_app.username
       mongo_password   = var.infra.mongodb_user_app.password
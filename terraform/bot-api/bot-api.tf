@@ -29,12 +29,14 @@ resource "kubernetes_secret" "app" {
     "config.yaml" = templatefile("${path.module}/config.template.yaml", {
       nats_url         = "nats.database.svc.cluster.local:4222"
       nats_bot_api     = var.nats_bot_api_subject
+      nats_changes     = var.nats_channel_changes_stream
       port             = "7777"
       mongo_uri        = var.infra.mongodb_uri
       mongo_username   = var.infra.mongodb_user_app.username
       mongo_password   = var.infra.mongodb_user_app.password
       mongo_database   = var.mongo_bot_database
       mongo_collection = var.mongo_bot_users_collection
+      stats_collection = var.mongo_bot_global_stats_collection
     })
   }
 }
@@ -10,6 +10,11 @@ variable "nats_bot_api_subject" {
   default = ""
 }
 
+variable "nats_channel_changes_stream" {
+  type    = string
+  default = "channelChanges"
+}
+
 variable "mongo_bot_database" {
   type    = string
   default = ""
@@ -20,6 +25,11 @@ variable "mongo_bot_users_collection" {
   default = ""
 }
 
+variable "mongo_bot_global_stats_collection" {
+  type    = string
+  default = ""
+}
+
 variable "image_url_template" {
   type    = string
   default = ""
@@ -42,8 +42,10 @@ resource "kubernetes_secret" "app" {
       redis_database   = 5
       nats_url         = "nats.database.svc.cluster.local:4222"
       nats_stream      = var.nats_twitch_irc_stream
+      nats_duplicates  = "1m"
       nats_irc_raw     = var.nats_irc_raw_subject
       nats_bot_api     = var.nats_bot_api_subject
+      nats_changes     = var.nats_channel_changes_stream
       mongo_uri        = var.infra.mongodb_uri
       mongo_username   = var.infra.mongodb_user_app.username
       mongo_password   = var.infra.mongodb_user_app.password
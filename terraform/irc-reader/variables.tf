@@ -50,6 +50,11 @@ variable "nats_bot_api_subject" {
   default = ""
 }
 
+variable "nats_channel_changes_stream" {
+  type    = string
+  default = "channelChanges"
+}
+
 variable "nats_twitch_irc_stream" {
   type    = string
   default = ""
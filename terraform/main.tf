@@ -30,16 +30,17 @@ module "irc-reader" {
   image_url_template = local.image_url_template
   infra              = local.infra
 
-  oauth_secret               = var.oauth_secret
-  twitch_username            = var.twitch_username
-  ratelimit_join             = var.ratelimit_join
-  ratelimit_auth             = var.ratelimit_auth
-  ratelimit_reset            = var.ratelimit_reset
-  nats_irc_raw_subject       = var.nats_irc_raw_subject
-  nats_bot_api_subject       = var.nats_bot_api_subject
-  nats_twitch_irc_stream     = var.nats_twitch_irc_stream
-  mongo_bot_database         = var.mongo_bot_database
-  mongo_bot_users_collection = var.mongo_bot_users_collection
+  oauth_secret                = var.oauth_secret
+  twitch_username             = var.twitch_username
+  ratelimit_join              = var.ratelimit_join
+  ratelimit_auth              = var.ratelimit_auth
+  ratelimit_reset             = var.ratelimit_reset
+  nats_irc_raw_subject        = var.nats_irc_raw_subject
+  nats_bot_api_subject        = var.nats_bot_api_subject
+  nats_twitch_irc_stream      = var.nats_twitch_irc_stream
+  nats_channel_changes_stream = var.nats_channel_changes_stream
+  mongo_bot_database          = var.mongo_bot_database
+  mongo_bot_users_collection  = var.mongo_bot_users_collection
 }
 
 module "bot-api" {
@@ -47,9 +48,11 @@ module "bot-api" {
   image_url_template = local.image_url_template
   infra              = local.infra
 
-  nats_bot_api_subject       = var.nats_bot_api_subject
-  mongo_bot_database         = var.mongo_bot_database
-  mongo_bot_users_collection = var.mongo_bot_users_collection
+  nats_bot_api_subject              = var.nats_bot_api_subject
+  nats_channel_changes_stream       = var.nats_channel_changes_stream
+  mongo_bot_database                = var.mongo_bot_database
+  mongo_bot_users_collection        = var.mongo_bot_users_collection
+  mongo_bot_global_stats_collection = var.mongo_bot_global_stats_collection
 }
 
 module "aggregator" {